@@ -12,6 +12,8 @@ import (
 
 	pkgdecimal "github.com/amanbolat/pkg/decimal"
 	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgpostgrestest "github.com/amanbolat/pkg/postgres/postgrestest"
+	pkgptr "github.com/amanbolat/pkg/ptr"
 	pkgsql "github.com/amanbolat/pkg/sql"
 	"github.com/go-jet/jet/v2/generator/metadata"
 	"github.com/go-jet/jet/v2/generator/postgres"
@@ -19,8 +21,6 @@ import (
 	jetpg "github.com/go-jet/jet/v2/postgres"
 	"github.com/jackc/pgx/v5/pgtype"
 	_ "github.com/lib/pq"
-	"github.com/testcontainers/testcontainers-go"
-	pgtestctr "github.com/testcontainers/testcontainers-go/modules/postgres"
 )
 
 func main() {
@@ -38,6 +38,7 @@ func run() error {
 	tablesToSkipParam := flag.String("skiptables", "", "tables to skip. Tables that will be skipped during the generation")
 	viewsToSkipParam := flag.String("skipviews", "", "views to skip. Views that will be skipped during the generation")
 	migrationFormatStr := flag.String("format", "flyway", "migration file format (flyway or gomigrate)")
+	backend := flag.String("backend", string(pkgpostgrestest.BackendAuto), "how to run postgres (auto, docker or local)")
 	flag.Parse()
 
 	migrationFormat, err := pkgsql.ParseMigrationFormat(*migrationFormatStr)
@@ -62,40 +63,27 @@ func run() error {
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Kill, os.Interrupt)
 	defer cancel()
 
-	slog.Info("starting postgres container")
+	slog.Info("starting postgres")
 
-	pgCtr, err := pgtestctr.RunContainer(ctx,
-		testcontainers.WithImage("docker.io/postgres:15.2-alpine"),
-	)
+	srv, err := pkgpostgrestest.StartServer(ctx, pkgpostgrestest.ServerConfig{
+		Backend: pkgptr.Ptr(pkgpostgrestest.Backend(*backend)),
+	})
 	if err != nil {
-		return fmt.Errorf("failed to run postgres container: %w", err)
+		return fmt.Errorf("failed to start postgres: %w", err)
 	}
 
 	defer func() {
-		err := pgCtr.Terminate(ctx)
+		err := srv.Close(context.Background())
 		if err != nil {
-			slog.Error("failed to terminate postgres container", slog.Any("error", err))
+			slog.Error("failed to stop postgres", slog.Any("error", err))
 		}
 	}()
 
-	dsn, err := pgCtr.ConnectionString(ctx, "sslmode=disable")
-	if err != nil {
-		return fmt.Errorf("failed to get postgres connection string: %w", err)
-	}
-
-	pgConn, err := pkgpostgres.NewSQLConn(ctx, pkgpostgres.SQLConnConfig{
-		DSN: dsn,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create postgres connection: %w", err)
-	}
-
-	err = pgConn.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close postgres connection: %w", err)
-	}
+	// The generated files are placed in a directory named after the database,
+	// so the migrations are applied to the default database.
+	dsn := srv.DSN()
 
-	slog.Info("postgres container started", slog.String("dsn", dsn))
+	slog.Info("postgres started", slog.String("dsn", pkgpostgres.RedactDSN(dsn)))
 
 	migrator, err := pkgsql.NewMigrator(pkgsql.MigratorConfig{
 		MigrationsDir: *migrationsPath,
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+const usage = `usage: migrate [flags] <command> [args]
+
+commands:
+  new <name>                      create up and down migration files
+  convert -from <fmt> -to <fmt>   convert migration files between formats
+  up [-dry-run] [-output fmt] [n] apply all or n up migrations or print them
+  down [n]                        apply all or n down migrations
+  status                          print the current migration version
+  force <version>                 set the version without running migrations
+  lint [-disable rule,...]        check migrations for dangerous DDL and version issues
+
+flags:
+`
+
+func main() {
+	err := run(os.Args[1:])
+	if err != nil {
+		slog.Error("failed to run migrate", slog.Any("error", err))
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", "./migrations", "path to database migrations")
+	dsn := flags.String("dsn", os.Getenv("DATABASE_URL"), "database connection string, defaults to $DATABASE_URL")
+	migrationFormatStr := flags.String("format", "flyway", "migration file format (flyway or gomigrate)")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() < 1 {
+		flags.Usage()
+
+		return errors.New("command is required")
+	}
+
+	migrationFormat, err := pkgsql.ParseMigrationFormat(*migrationFormatStr)
+	if err != nil {
+		return fmt.Errorf("failed to parse migration format: %w", err)
+	}
+
+	cmd, cmdArgs := flags.Arg(0), flags.Args()[1:]
+
+	switch cmd {
+	case "new":
+		return runNew(*dir, migrationFormat, cmdArgs)
+	case "convert":
+		return runConvert(*dir, cmdArgs)
+	case "lint":
+		return runLint(*dir, migrationFormat, cmdArgs)
+	case "up", "down", "status", "force":
+	default:
+		flags.Usage()
+
+		return fmt.Errorf("unknown command %s", cmd)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	migrator, err := pkgsql.NewMigrator(pkgsql.MigratorConfig{
+		MigrationsDir: *dir,
+		DSN:           *dsn,
+		Format:        migrationFormat,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	defer func() {
+		err := migrator.Close()
+		if err != nil {
+			slog.Error("failed to close migrator", slog.Any("error", err))
+		}
+	}()
+
+	switch cmd {
+	case "up":
+		return runUp(ctx, migrator, cmdArgs)
+	case "down":
+		return runDown(migrator, cmdArgs)
+	case "status":
+		return runStatus(migrator)
+	default:
+		return runForce(migrator, cmdArgs)
+	}
+}
+
+func runNew(dir string, format pkgsql.MigrationFormat, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate new <name>")
+	}
+
+	up, down, err := pkgsql.MigrationFileNames(format, time.Now(), args[0])
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations dir %s: %w", dir, err)
+	}
+
+	for _, name := range []string{up, down} {
+		path := filepath.Join(dir, name)
+
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to create migration file %s: %w", path, err)
+		}
+
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+
+		slog.Info("created migration file", slog.String("path", path))
+	}
+
+	return nil
+}
+
+func runConvert(dir string, args []string) error {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	fromStr := flags.String("from", "flyway", "source migration file format")
+	toStr := flags.String("to", "gomigrate", "destination migration file format")
+	out := flags.String("out", "", "destination dir, files are renamed in place if empty")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	from, err := pkgsql.ParseMigrationFormat(*fromStr)
+	if err != nil {
+		return fmt.Errorf("failed to parse source migration format: %w", err)
+	}
+
+	to, err := pkgsql.ParseMigrationFormat(*toStr)
+	if err != nil {
+		return fmt.Errorf("failed to parse destination migration format: %w", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read migrations dir %s: %w", dir, err)
+	}
+
+	if *out != "" {
+		err = os.MkdirAll(*out, 0o755)
+		if err != nil {
+			return fmt.Errorf("failed to create destination dir %s: %w", *out, err)
+		}
+	}
+
+	// All the names are converted before touching the files, so an invalid
+	// name doesn't leave the directory half converted.
+	newNames := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		newName, err := pkgsql.ConvertMigrationFileName(entry.Name(), from, to)
+		if err != nil {
+			return err
+		}
+		newNames[entry.Name()] = newName
+	}
+
+	for _, entry := range entries {
+		newName, ok := newNames[entry.Name()]
+		if !ok {
+			continue
+		}
+
+		src := filepath.Join(dir, entry.Name())
+		if *out == "" {
+			err = os.Rename(src, filepath.Join(dir, newName))
+		} else {
+			err = copyFile(src, filepath.Join(*out, newName))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to convert migration file %s: %w", src, err)
+		}
+
+		slog.Info("converted migration file", slog.String("from", entry.Name()), slog.String("to", newName))
+	}
+
+	return nil
+}
+
+func runLint(dir string, format pkgsql.MigrationFormat, args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	disable := flags.String("disable", "", "comma separated list of rules to disable")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	var opts pkgsql.LintOptions
+	for _, rule := range strings.Split(*disable, ",") {
+		if rule != "" {
+			opts.DisabledRules = append(opts.DisabledRules, pkgsql.LintRule(strings.TrimSpace(rule)))
+		}
+	}
+
+	issues, err := pkgsql.LintMigrations(pkgsql.MigratorConfig{
+		MigrationsDir: dir,
+		Format:        format,
+	}, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, issue := range issues {
+		fmt.Println(issue.String())
+	}
+
+	if pkgsql.HasLintErrors(issues) {
+		return fmt.Errorf("found %d lint issues", len(issues))
+	}
+
+	return nil
+}
+
+func runUp(ctx context.Context, migrator *pkgsql.Migrator, args []string) error {
+	flags := flag.NewFlagSet("up", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print pending migrations without applying them")
+	output := flags.String("output", "text", "dry run output format (text or json)")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+
+	n, err := parseSteps(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		plan, err := migrator.Plan(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to plan migrations: %w", err)
+		}
+
+		if n > 0 && n < len(plan) {
+			plan = plan[:n]
+		}
+
+		return pkgsql.WritePlan(os.Stdout, plan, pkgsql.PlanOutputFormat(*output))
+	}
+
+	if n == 0 {
+		err = migrator.MigrateUp()
+	} else {
+		err = migrator.Steps(n)
+	}
+
+	return ignoreNoChange(err)
+}
+
+func runDown(migrator *pkgsql.Migrator, args []string) error {
+	n, err := parseSteps(args)
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		err = migrator.MigrateDown()
+	} else {
+		err = migrator.Steps(-n)
+	}
+
+	return ignoreNoChange(err)
+}
+
+func runStatus(migrator *pkgsql.Migrator) error {
+	version, dirty, err := migrator.Version()
+	if errors.Is(err, pkgsql.ErrNoVersion) {
+		slog.Info("no migrations have been applied")
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get migration version: %w", err)
+	}
+
+	slog.Info("migration status", slog.Uint64("version", uint64(version)), slog.Bool("dirty", dirty))
+
+	return nil
+}
+
+func runForce(migrator *pkgsql.Migrator, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: migrate force <version>")
+	}
+
+	version, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid version %s: %w", args[0], err)
+	}
+
+	return migrator.Force(version)
+}
+
+func parseSteps(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid number of steps %s", args[0])
+	}
+
+	return n, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, pkgsql.ErrNoChange) {
+		slog.Info("no migrations to apply")
+
+		return nil
+	}
+
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,136 @@
+package pkgpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+const (
+	advisoryLockRetryDelay    = time.Millisecond * 10
+	advisoryLockMaxRetryDelay = time.Second
+)
+
+// ErrAdvisoryLockNotHeld is returned by AdvisoryLock.Unlock when the
+// session doesn't hold the lock.
+var ErrAdvisoryLockNotHeld = errors.New("advisory lock is not held")
+
+// AdvisoryLock is a Postgres advisory lock identified by an int64 key.
+//
+// Session scoped locks are held until they are unlocked or the connection
+// is closed, so they must be used with a dedicated *sql.Conn. Transaction
+// scoped locks are released at the end of the transaction.
+type AdvisoryLock struct {
+	key int64
+}
+
+// NewAdvisoryLock returns the lock with the key.
+func NewAdvisoryLock(key int64) AdvisoryLock {
+	return AdvisoryLock{key: key}
+}
+
+// NewAdvisoryLockFromString returns the lock with the key computed
+// as 64-bit FNV-1a hash of the name.
+func NewAdvisoryLockFromString(name string) AdvisoryLock {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+
+	return AdvisoryLock{key: int64(h.Sum64())}
+}
+
+// Key returns the key of the lock.
+func (l AdvisoryLock) Key() int64 {
+	return l.key
+}
+
+// Lock acquires the session scoped lock and waits until it is available.
+// Waiting is stopped when the context is canceled.
+//
+// The lock is polled with pg_try_advisory_lock with a growing delay instead
+// of waiting in pg_advisory_lock. pgx closes the connection when a running
+// statement is canceled, which would release all the other session locks
+// held by conn.
+func (l AdvisoryLock) Lock(ctx context.Context, conn *sql.Conn) error {
+	for attempt := 1; ; attempt++ {
+		ok, err := l.TryLock(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(backoffDelay(attempt, advisoryLockRetryDelay, advisoryLockMaxRetryDelay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("failed to acquire advisory lock %d: %w", l.key, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// TryLock acquires the session scoped lock if it is available and reports
+// whether it was acquired.
+func (l AdvisoryLock) TryLock(ctx context.Context, conn *sql.Conn) (bool, error) {
+	return l.queryBool(ctx, conn, "SELECT pg_try_advisory_lock($1)")
+}
+
+// Unlock releases the session scoped lock. The lock must be released as
+// many times as it was acquired.
+func (l AdvisoryLock) Unlock(ctx context.Context, conn *sql.Conn) error {
+	ok, err := l.queryBool(ctx, conn, "SELECT pg_advisory_unlock($1)")
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return ErrAdvisoryLockNotHeld
+	}
+
+	return nil
+}
+
+// LockTx acquires the transaction scoped lock and waits until it is available.
+// The lock is released when the transaction ends.
+func (l AdvisoryLock) LockTx(ctx context.Context, tx pkgsql.Execer) error {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", l.key)
+	if err != nil {
+		return fmt.Errorf("failed to acquire advisory lock %d: %w", l.key, err)
+	}
+
+	return nil
+}
+
+// TryLockTx acquires the transaction scoped lock if it is available and
+// reports whether it was acquired.
+func (l AdvisoryLock) TryLockTx(ctx context.Context, tx pkgsql.Querier) (bool, error) {
+	return l.queryBool(ctx, tx, "SELECT pg_try_advisory_xact_lock($1)")
+}
+
+func (l AdvisoryLock) queryBool(ctx context.Context, q pkgsql.Querier, query string) (bool, error) {
+	rows, err := q.QueryContext(ctx, query, l.key)
+	if err != nil {
+		return false, fmt.Errorf("failed to query advisory lock %d: %w", l.key, err)
+	}
+	defer rows.Close()
+
+	var res bool
+	if rows.Next() {
+		err = rows.Scan(&res)
+	} else {
+		err = rows.Err()
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query advisory lock %d: %w", l.key, err)
+	}
+
+	return res, nil
+}
@@ -0,0 +1,91 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAdvisoryLockFromString(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, int64(42), pkgpostgres.NewAdvisoryLock(42).Key())
+	assert.Equal(t, pkgpostgres.NewAdvisoryLockFromString("report").Key(), pkgpostgres.NewAdvisoryLockFromString("report").Key())
+	assert.NotEqual(t, pkgpostgres.NewAdvisoryLockFromString("report").Key(), pkgpostgres.NewAdvisoryLockFromString("cleanup").Key())
+}
+
+func TestAdvisoryLock_Lock(t *testing.T) {
+	t.Parallel()
+
+	lock := pkgpostgres.NewAdvisoryLock(42)
+
+	// The lock is polled until it becomes available.
+	var attempts, availableAt = 0, 3
+	conn := &stubConn{
+		query: func(string, []any) ([]string, [][]driver.Value, error) {
+			attempts++
+
+			return []string{"pg_try_advisory_lock"}, [][]driver.Value{{attempts == availableAt}}, nil
+		},
+	}
+
+	sqlConn, err := newStubDB(t, conn).Conn(context.Background())
+	require.NoError(t, err)
+	defer sqlConn.Close()
+
+	err = lock.Lock(context.Background(), sqlConn)
+	require.NoError(t, err)
+	assert.Equal(t, []stubStatement{
+		{query: "SELECT pg_try_advisory_lock($1)", args: []any{int64(42)}},
+		{query: "SELECT pg_try_advisory_lock($1)", args: []any{int64(42)}},
+		{query: "SELECT pg_try_advisory_lock($1)", args: []any{int64(42)}},
+	}, conn.Statements())
+
+	// Waiting for the lock is stopped when the context is canceled.
+	availableAt = 0
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err = lock.Lock(ctx, sqlConn)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestAdvisoryLock_LockCanceled(t *testing.T) {
+	srv := startServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	conn, err := pkgpostgres.NewSQLConn(ctx, pkgpostgres.SQLConnConfig{DSN: srv.NewDatabase(t)})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	holder, err := conn.Conn(ctx)
+	require.NoError(t, err)
+	defer holder.Close()
+
+	waiter, err := conn.Conn(ctx)
+	require.NoError(t, err)
+	defer waiter.Close()
+
+	held := pkgpostgres.NewAdvisoryLockFromString("advisory_lock_held")
+	other := pkgpostgres.NewAdvisoryLockFromString("advisory_lock_other")
+
+	require.NoError(t, held.Lock(ctx, holder))
+	require.NoError(t, other.Lock(ctx, waiter))
+
+	lockCtx, lockCancel := context.WithTimeout(ctx, time.Millisecond*200)
+	defer lockCancel()
+
+	err = held.Lock(lockCtx, waiter)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The canceled wait keeps the connection and its other locks.
+	assert.NoError(t, other.Unlock(ctx, waiter))
+	assert.NoError(t, held.Unlock(ctx, holder))
+}
@@ -0,0 +1,328 @@
+package pkgpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const DefaultCopyProgressRows = 10000
+
+// ErrNotPgxConn is returned when the Execer passed to CopyFrom or CopyTo
+// doesn't use pgx driver under the hood.
+var ErrNotPgxConn = errors.New("execer doesn't use pgx driver")
+
+// CopyFormat is a format of the data written by CopyTo.
+type CopyFormat string
+
+const (
+	CopyFormatCSV    CopyFormat = "csv"
+	CopyFormatBinary CopyFormat = "binary"
+)
+
+// CopyProgress is passed to CopyOptions.OnProgress.
+type CopyProgress struct {
+	// Rows is the number of rows sent by CopyFrom so far.
+	Rows int64
+	// Bytes is the number of bytes written by CopyTo so far.
+	Bytes int64
+}
+
+// CopySource is an iterator over the rows sent by CopyFrom.
+// pgx.CopyFromRows, pgx.CopyFromSlice and pgx.CopyFromFunc can be used
+// to create one.
+type CopySource = pgx.CopyFromSource
+
+type CopyOptions struct {
+	// OnProgress is called while the data is being copied.
+	OnProgress func(p CopyProgress)
+	// ProgressRows is the number of rows between two OnProgress calls of CopyFrom.
+	ProgressRows *int64
+	// Header adds a header line to the CSV output of CopyTo.
+	Header bool
+}
+
+func (o CopyOptions) progressRows() int64 {
+	if o.ProgressRows == nil || *o.ProgressRows <= 0 {
+		return DefaultCopyProgressRows
+	}
+
+	return *o.ProgressRows
+}
+
+// CopyFrom copies the rows into the table using COPY FROM with the binary
+// format. It returns the number of copied rows. pkgdecimal.Decimal values
+// are encoded as numeric without loss of precision.
+//
+// e must use pgx driver under the hood, e.g. *sql.DB created by NewSQLConn
+// or PoolConn.SQLDB. When e is *sql.Tx, e.g. inside pkgsql.AtomicStore,
+// the rows are copied within the transaction.
+func CopyFrom(ctx context.Context, e pkgsql.Execer, table string, columns []string, rows CopySource, opts CopyOptions) (int64, error) {
+	var n int64
+
+	err := withPgxConn(ctx, e, func(conn *pgx.Conn) error {
+		var err error
+		n, err = conn.CopyFrom(ctx, parseIdentifier(table), columns, &copySource{
+			src:   rows,
+			opts:  opts,
+			every: opts.progressRows(),
+		})
+
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy rows to %s: %w", table, err)
+	}
+
+	if opts.OnProgress != nil && n%opts.progressRows() != 0 {
+		opts.OnProgress(CopyProgress{Rows: n})
+	}
+
+	return n, nil
+}
+
+// CopyTo writes the result of the query to w using COPY TO in the given
+// format. It returns the number of copied rows.
+//
+// e must use pgx driver under the hood. When e is *sql.Tx, the query
+// runs within the transaction.
+func CopyTo(ctx context.Context, e pkgsql.Execer, query string, w io.Writer, format CopyFormat, opts CopyOptions) (int64, error) {
+	var options []string
+	switch format {
+	case CopyFormatCSV:
+		options = append(options, "FORMAT csv")
+		if opts.Header {
+			options = append(options, "HEADER true")
+		}
+	case CopyFormatBinary:
+		options = append(options, "FORMAT binary")
+	default:
+		return 0, fmt.Errorf("unknown copy format %s", format)
+	}
+
+	stmt := fmt.Sprintf("COPY (%s) TO STDOUT WITH (%s)", strings.TrimSuffix(strings.TrimSpace(query), ";"), strings.Join(options, ", "))
+
+	var n int64
+
+	err := withPgxConn(ctx, e, func(conn *pgx.Conn) error {
+		tag, err := conn.PgConn().CopyTo(ctx, &progressWriter{w: w, onProgress: opts.OnProgress}, stmt)
+		n = tag.RowsAffected()
+
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy query result: %w", err)
+	}
+
+	return n, nil
+}
+
+// CopyFrom calls CopyFrom with the connection.
+func (s SQLConn) CopyFrom(ctx context.Context, table string, columns []string, rows CopySource, opts CopyOptions) (int64, error) {
+	return CopyFrom(ctx, s.DB, table, columns, rows, opts)
+}
+
+// CopyTo calls CopyTo with the connection.
+func (s SQLConn) CopyTo(ctx context.Context, query string, w io.Writer, format CopyFormat, opts CopyOptions) (int64, error) {
+	return CopyTo(ctx, s.DB, query, w, format, opts)
+}
+
+// parseIdentifier splits a possibly schema qualified name, e.g.
+// public.users or "my.schema"."my ""table""". The parts are kept as is,
+// so the names are matched case-sensitively.
+func parseIdentifier(name string) pgx.Identifier {
+	return splitIdentifiers(name, '.')
+}
+
+// splitIdentifiers splits s by sep outside of the double quotes. Doubled
+// quotes are unescaped and the whitespace outside of the quotes is dropped.
+func splitIdentifiers(s string, sep byte) []string {
+	var (
+		parts  []string
+		part   strings.Builder
+		quoted bool
+	)
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case c == '"' && quoted && i+1 < len(s) && s[i+1] == '"':
+			part.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+		case c == sep && !quoted:
+			parts = append(parts, part.String())
+			part.Reset()
+		case (c == ' ' || c == '\t' || c == '\n') && !quoted:
+		default:
+			part.WriteByte(c)
+		}
+	}
+
+	return append(parts, part.String())
+}
+
+// pgxConnRewriter gives access to *pgx.Conn used by database/sql.
+// pgx calls RewriteQuery with its connection when the rewriter is the
+// first argument of the query, so fn runs on the same connection and
+// within the same transaction as the query.
+type pgxConnRewriter struct {
+	fn     func(conn *pgx.Conn) error
+	called bool
+}
+
+func (r *pgxConnRewriter) RewriteQuery(_ context.Context, conn *pgx.Conn, sql string, args []any) (string, []any, error) {
+	r.called = true
+
+	err := r.fn(conn)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return sql, args, nil
+}
+
+// withPgxConn calls fn with the *pgx.Conn that e executes queries on.
+func withPgxConn(ctx context.Context, e pkgsql.Execer, fn func(conn *pgx.Conn) error) error {
+	rewriter := &pgxConnRewriter{fn: fn}
+
+	_, err := e.ExecContext(ctx, "SELECT 1", rewriter)
+	if !rewriter.called {
+		if err != nil {
+			return errors.Join(ErrNotPgxConn, err)
+		}
+
+		return ErrNotPgxConn
+	}
+
+	return err
+}
+
+// copySource converts the values of the rows and reports the progress.
+type copySource struct {
+	src   CopySource
+	opts  CopyOptions
+	every int64
+	rows  int64
+	err   error
+}
+
+func (s *copySource) Next() bool {
+	if s.err != nil || !s.src.Next() {
+		return false
+	}
+
+	s.rows++
+	if s.opts.OnProgress != nil && s.rows%s.every == 0 {
+		s.opts.OnProgress(CopyProgress{Rows: s.rows})
+	}
+
+	return true
+}
+
+func (s *copySource) Values() ([]any, error) {
+	values, err := s.src.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range values {
+		values[i], err = copyValue(v)
+		if err != nil {
+			s.err = err
+
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func (s *copySource) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+
+	return s.src.Err()
+}
+
+func copyValue(v any) (any, error) {
+	switch v := v.(type) {
+	case pkgdecimal.Decimal:
+		return decimalToNumeric(v)
+	case *pkgdecimal.Decimal:
+		if v == nil {
+			return nil, nil
+		}
+
+		return decimalToNumeric(*v)
+	default:
+		return v, nil
+	}
+}
+
+// decimalToNumeric converts the decimal to pgtype.Numeric without
+// loss of precision.
+func decimalToNumeric(d pkgdecimal.Decimal) (pgtype.Numeric, error) {
+	s := d.String()
+
+	switch s {
+	case "NaN", "-NaN", "sNaN", "-sNaN":
+		return pgtype.Numeric{NaN: true, Valid: true}, nil
+	case "Infinity":
+		return pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true}, nil
+	case "-Infinity":
+		return pgtype.Numeric{InfinityModifier: pgtype.NegativeInfinity, Valid: true}, nil
+	}
+
+	mantissa, exp := s, int64(0)
+	if idx := strings.IndexAny(s, "eE"); idx >= 0 {
+		var err error
+		exp, err = strconv.ParseInt(s[idx+1:], 10, 32)
+		if err != nil {
+			return pgtype.Numeric{}, fmt.Errorf("invalid decimal %s: %w", s, err)
+		}
+		mantissa = s[:idx]
+	}
+
+	if idx := strings.IndexByte(mantissa, '.'); idx >= 0 {
+		exp -= int64(len(mantissa) - idx - 1)
+		mantissa = mantissa[:idx] + mantissa[idx+1:]
+	}
+
+	i, ok := new(big.Int).SetString(mantissa, 10)
+	if !ok {
+		return pgtype.Numeric{}, fmt.Errorf("invalid decimal %s", s)
+	}
+
+	return pgtype.Numeric{Int: i, Exp: int32(exp), Valid: true}, nil
+}
+
+// progressWriter counts the written bytes and reports them.
+type progressWriter struct {
+	w          io.Writer
+	onProgress func(p CopyProgress)
+	bytes      int64
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.bytes += int64(n)
+
+	if w.onProgress != nil {
+		w.onProgress(CopyProgress{Bytes: w.bytes})
+	}
+
+	return n, err
+}
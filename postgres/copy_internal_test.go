@@ -0,0 +1,60 @@
+package pkgpostgres
+
+import (
+	"math/big"
+	"testing"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIdentifier(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		want pgx.Identifier
+	}{
+		{name: "users", want: pgx.Identifier{"users"}},
+		{name: "public.users", want: pgx.Identifier{"public", "users"}},
+		{name: `"my.schema".users`, want: pgx.Identifier{"my.schema", "users"}},
+		{name: `public."Order ""Items"""`, want: pgx.Identifier{"public", `Order "Items"`}},
+		{name: `o.created_at`, want: pgx.Identifier{"o", "created_at"}},
+		{name: `public . " users"`, want: pgx.Identifier{"public", " users"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, parseIdentifier(tt.name), tt.name)
+	}
+}
+
+func TestDecimalToNumeric(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		decimal string
+		want    pgtype.Numeric
+	}{
+		{decimal: "0", want: pgtype.Numeric{Int: big.NewInt(0), Valid: true}},
+		{decimal: "123.4500", want: pgtype.Numeric{Int: big.NewInt(1234500), Exp: -4, Valid: true}},
+		{decimal: "-0.001", want: pgtype.Numeric{Int: big.NewInt(-1), Exp: -3, Valid: true}},
+		{decimal: "1.5E+10", want: pgtype.Numeric{Int: big.NewInt(15), Exp: 9, Valid: true}},
+		{decimal: "12E-12", want: pgtype.Numeric{Int: big.NewInt(12), Exp: -12, Valid: true}},
+		{decimal: "1E+3", want: pgtype.Numeric{Int: big.NewInt(1), Exp: 3, Valid: true}},
+		{decimal: "NaN", want: pgtype.Numeric{NaN: true, Valid: true}},
+		{decimal: "Infinity", want: pgtype.Numeric{InfinityModifier: pgtype.Infinity, Valid: true}},
+		{decimal: "-Infinity", want: pgtype.Numeric{InfinityModifier: pgtype.NegativeInfinity, Valid: true}},
+	}
+
+	for _, tt := range tests {
+		d, err := pkgdecimal.FromStr(tt.decimal)
+		require.NoError(t, err, tt.decimal)
+
+		got, err := decimalToNumeric(d)
+		require.NoError(t, err, tt.decimal)
+		assert.Equal(t, tt.want, got, "%s (%s)", tt.decimal, d.String())
+	}
+}
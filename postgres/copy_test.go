@@ -0,0 +1,35 @@
+package pkgpostgres_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type execerFunc func(ctx context.Context, query string, args ...any) (sql.Result, error)
+
+func (f execerFunc) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return f(ctx, query, args...)
+}
+
+func TestCopy_NotPgxConn(t *testing.T) {
+	t.Parallel()
+
+	errDriver := errors.New("unsupported type")
+	execer := execerFunc(func(context.Context, string, ...any) (sql.Result, error) {
+		return nil, errDriver
+	})
+
+	_, err := pkgpostgres.CopyTo(context.Background(), execer, "SELECT 1", &bytes.Buffer{}, pkgpostgres.CopyFormatCSV, pkgpostgres.CopyOptions{})
+	require.ErrorIs(t, err, pkgpostgres.ErrNotPgxConn)
+	assert.ErrorIs(t, err, errDriver)
+
+	_, err = pkgpostgres.CopyTo(context.Background(), execer, "SELECT 1", &bytes.Buffer{}, "xml", pkgpostgres.CopyOptions{})
+	assert.ErrorContains(t, err, "unknown copy format")
+}
@@ -0,0 +1,673 @@
+package pkgpostgres
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPort = 5432
+	// redactedPassword replaces the password in the redacted DSN.
+	redactedPassword = "xxxxx"
+)
+
+// dsnSecretKeys are the keywords whose values are redacted together with
+// the password.
+var dsnSecretKeys = []string{"sslpassword"}
+
+// DSN keywords handled by DSNConfig fields. Other keywords are kept in DSNConfig.Params.
+const (
+	dsnKeyHost             = "host"
+	dsnKeyPort             = "port"
+	dsnKeyDatabase         = "dbname"
+	dsnKeyUser             = "user"
+	dsnKeyPassword         = "password"
+	dsnKeyPassfile         = "passfile"
+	dsnKeySSLMode          = "sslmode"
+	dsnKeySSLCert          = "sslcert"
+	dsnKeySSLKey           = "sslkey"
+	dsnKeySSLRootCert      = "sslrootcert"
+	dsnKeyApplicationName  = "application_name"
+	dsnKeyStatementTimeout = "statement_timeout"
+	dsnKeySearchPath       = "search_path"
+)
+
+// dsnEnvVars maps the libpq environment variables to the DSN keywords.
+var dsnEnvVars = map[string]string{
+	"PGHOST":        dsnKeyHost,
+	"PGPORT":        dsnKeyPort,
+	"PGDATABASE":    dsnKeyDatabase,
+	"PGUSER":        dsnKeyUser,
+	"PGPASSWORD":    dsnKeyPassword,
+	"PGPASSFILE":    dsnKeyPassfile,
+	"PGSSLMODE":     dsnKeySSLMode,
+	"PGSSLCERT":     dsnKeySSLCert,
+	"PGSSLKEY":      dsnKeySSLKey,
+	"PGSSLROOTCERT": dsnKeySSLRootCert,
+	"PGAPPNAME":     dsnKeyApplicationName,
+}
+
+// DSNHost is a host of the Postgres server. Host can also be a path
+// to the directory of the unix socket.
+type DSNHost struct {
+	Host string
+	// Port is DefaultPort if it is zero.
+	Port uint16
+}
+
+// DSNConfig is a structured Postgres connection string.
+//
+// Example:
+//
+//	dsn := pkgpostgres.DSNConfig{
+//	    Hosts:    []pkgpostgres.DSNHost{{Host: "localhost"}},
+//	    Database: "app",
+//	    User:     "app",
+//	    Password: password,
+//	    SSLMode:  "disable",
+//	}
+//	conn, err := pkgpostgres.NewSQLConn(ctx, pkgpostgres.SQLConnConfig{DSN: dsn.URL()})
+//	slog.Info("connected to postgres", slog.Any("dsn", dsn)) // the password is redacted
+type DSNConfig struct {
+	Hosts    []DSNHost
+	Database string
+	User     string
+	Password string
+	// Passfile is a path to the password file used by WithPassfile.
+	Passfile        string
+	SSLMode         string
+	SSLCert         string
+	SSLKey          string
+	SSLRootCert     string
+	ApplicationName string
+	// StatementTimeout and SearchPath are encoded in the connection string
+	// the same way as SessionSettings.RuntimeParams does it. The timeout
+	// is not set if it is zero.
+	StatementTimeout time.Duration
+	SearchPath       []string
+	// Params are the other parameters, e.g. connect_timeout.
+	Params map[string]string
+}
+
+// ParseDSN parses the connection string in the URL or keyword/value format.
+func ParseDSN(dsn string) (DSNConfig, error) {
+	var (
+		settings map[string]string
+		err      error
+	)
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		settings, err = parseURLSettings(dsn)
+	} else {
+		settings, err = parseKeywordValueSettings(dsn)
+	}
+	if err != nil {
+		return DSNConfig{}, err
+	}
+
+	return dsnConfigFromSettings(settings)
+}
+
+// DSNConfigFromEnv returns DSNConfig built from the libpq environment
+// variables, e.g. PGHOST, PGUSER and PGPASSWORD.
+func DSNConfigFromEnv() (DSNConfig, error) {
+	return DSNConfig{}.WithEnv()
+}
+
+// WithEnv returns a copy of the config with the empty fields set from the
+// libpq environment variables.
+func (c DSNConfig) WithEnv() (DSNConfig, error) {
+	settings := make(map[string]string)
+	for env, key := range dsnEnvVars {
+		if v, ok := os.LookupEnv(env); ok && v != "" {
+			settings[key] = v
+		}
+	}
+
+	envCfg, err := dsnConfigFromSettings(settings)
+	if err != nil {
+		return DSNConfig{}, fmt.Errorf("invalid environment variables: %w", err)
+	}
+
+	if len(c.Hosts) == 0 {
+		c.Hosts = envCfg.Hosts
+	}
+
+	fields := []struct {
+		dst *string
+		src string
+	}{
+		{&c.Database, envCfg.Database},
+		{&c.User, envCfg.User},
+		{&c.Password, envCfg.Password},
+		{&c.Passfile, envCfg.Passfile},
+		{&c.SSLMode, envCfg.SSLMode},
+		{&c.SSLCert, envCfg.SSLCert},
+		{&c.SSLKey, envCfg.SSLKey},
+		{&c.SSLRootCert, envCfg.SSLRootCert},
+		{&c.ApplicationName, envCfg.ApplicationName},
+	}
+	for _, f := range fields {
+		if *f.dst == "" {
+			*f.dst = f.src
+		}
+	}
+
+	return c, nil
+}
+
+// WithPassfile returns a copy of the config with the password looked up
+// in the password file if the password is empty. The file is Passfile,
+// or ~/.pgpass if it is empty. A missing file is not an error.
+func (c DSNConfig) WithPassfile() (DSNConfig, error) {
+	if c.Password != "" {
+		return c, nil
+	}
+
+	path := c.Passfile
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return c, nil
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return DSNConfig{}, fmt.Errorf("failed to open passfile: %w", err)
+	}
+	defer f.Close()
+
+	host, port := "localhost", strconv.Itoa(DefaultPort)
+	if len(c.Hosts) > 0 {
+		if c.Hosts[0].Host != "" {
+			host = c.Hosts[0].Host
+		}
+		if c.Hosts[0].Port != 0 {
+			port = strconv.Itoa(int(c.Hosts[0].Port))
+		}
+	}
+	if strings.HasPrefix(host, "/") {
+		host = "localhost"
+	}
+
+	database := c.Database
+	if database == "" {
+		database = c.User
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+
+		fields := splitPassfileLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+
+		if passfileFieldMatches(fields[0], host) &&
+			passfileFieldMatches(fields[1], port) &&
+			passfileFieldMatches(fields[2], database) &&
+			passfileFieldMatches(fields[3], c.User) {
+			c.Password = fields[4]
+
+			return c, nil
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return DSNConfig{}, fmt.Errorf("failed to read passfile: %w", err)
+	}
+
+	return c, nil
+}
+
+// URL returns the connection string in the URL format.
+func (c DSNConfig) URL() string {
+	return c.url(false)
+}
+
+// KeywordValue returns the connection string in the keyword/value format.
+func (c DSNConfig) KeywordValue() string {
+	return c.keywordValue(false)
+}
+
+// Redacted returns the connection string in the URL format with the password
+// and other secrets, e.g. sslpassword, redacted.
+func (c DSNConfig) Redacted() string {
+	return c.url(true)
+}
+
+// String returns the redacted connection string, so the config can be printed safely.
+func (c DSNConfig) String() string {
+	return c.Redacted()
+}
+
+// LogValue implements slog.LogValuer.
+func (c DSNConfig) LogValue() slog.Value {
+	return slog.StringValue(c.Redacted())
+}
+
+// RedactDSN returns the connection string with the password and other
+// secrets, e.g. sslpassword, redacted. The connection strings that can't
+// be parsed are redacted completely.
+func RedactDSN(dsn string) string {
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return "[invalid dsn]"
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		return cfg.url(true)
+	}
+
+	return cfg.keywordValue(true)
+}
+
+// password returns the password of the config, redacted if redact is set.
+func (c DSNConfig) password(redact bool) string {
+	if redact && c.Password != "" {
+		return redactedPassword
+	}
+
+	return c.Password
+}
+
+// settings returns the keyword/value pairs of the config except for
+// the hosts, ports and password. The values of dsnSecretKeys are
+// redacted if redact is set.
+func (c DSNConfig) settings(redact bool) map[string]string {
+	settings := make(map[string]string, len(c.Params)+10)
+	for k, v := range c.Params {
+		settings[k] = v
+	}
+
+	fields := map[string]string{
+		dsnKeyDatabase:        c.Database,
+		dsnKeyUser:            c.User,
+		dsnKeyPassfile:        c.Passfile,
+		dsnKeySSLMode:         c.SSLMode,
+		dsnKeySSLCert:         c.SSLCert,
+		dsnKeySSLKey:          c.SSLKey,
+		dsnKeySSLRootCert:     c.SSLRootCert,
+		dsnKeyApplicationName: c.ApplicationName,
+	}
+	for k, v := range fields {
+		if v != "" {
+			settings[k] = v
+		}
+	}
+
+	session := SessionSettings{SearchPath: c.SearchPath}
+	if c.StatementTimeout > 0 {
+		session.StatementTimeout = &c.StatementTimeout
+	}
+	for k, v := range session.RuntimeParams() {
+		settings[k] = v
+	}
+
+	if redact {
+		for _, k := range dsnSecretKeys {
+			if settings[k] != "" {
+				settings[k] = redactedPassword
+			}
+		}
+	}
+
+	return settings
+}
+
+func (c DSNConfig) hostsAndPorts() (hosts, ports []string) {
+	for _, h := range c.Hosts {
+		port := h.Port
+		if port == 0 {
+			port = DefaultPort
+		}
+
+		hosts = append(hosts, h.Host)
+		ports = append(ports, strconv.Itoa(int(port)))
+	}
+
+	return hosts, ports
+}
+
+func (c DSNConfig) url(redact bool) string {
+	u := url.URL{Scheme: "postgres"}
+
+	settings := c.settings(redact)
+	password := c.password(redact)
+	delete(settings, dsnKeyDatabase)
+	delete(settings, dsnKeyUser)
+
+	if c.User != "" {
+		u.User = url.User(c.User)
+		if password != "" {
+			u.User = url.UserPassword(c.User, password)
+		}
+	}
+
+	hosts, ports := c.hostsAndPorts()
+	var hostPorts []string
+	for i, host := range hosts {
+		// Unix socket directories can't be a part of the URL host.
+		if strings.HasPrefix(host, "/") {
+			settings[dsnKeyHost] = strings.Join(hosts, ",")
+			settings[dsnKeyPort] = strings.Join(ports, ",")
+			hostPorts = nil
+
+			break
+		}
+		hostPorts = append(hostPorts, net.JoinHostPort(host, ports[i]))
+	}
+	u.Host = strings.Join(hostPorts, ",")
+
+	if c.Database != "" {
+		u.Path = "/" + c.Database
+	}
+
+	query := url.Values{}
+	for k, v := range settings {
+		query.Set(k, v)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func (c DSNConfig) keywordValue(redact bool) string {
+	settings := c.settings(redact)
+	if password := c.password(redact); password != "" {
+		settings[dsnKeyPassword] = password
+	}
+
+	hosts, ports := c.hostsAndPorts()
+	if len(hosts) > 0 {
+		settings[dsnKeyHost] = strings.Join(hosts, ",")
+		settings[dsnKeyPort] = strings.Join(ports, ",")
+	}
+
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+quoteDSNValue(settings[k]))
+	}
+
+	return strings.Join(pairs, " ")
+}
+
+func dsnConfigFromSettings(settings map[string]string) (DSNConfig, error) {
+	var cfg DSNConfig
+
+	hosts := splitDSNList(settings[dsnKeyHost])
+	ports := splitDSNList(settings[dsnKeyPort])
+	if len(ports) > 1 && len(ports) != len(hosts) {
+		return DSNConfig{}, fmt.Errorf("number of ports %d doesn't match number of hosts %d", len(ports), len(hosts))
+	}
+
+	for i, host := range hosts {
+		h := DSNHost{Host: host}
+
+		var port string
+		switch {
+		case len(ports) == 1:
+			port = ports[0]
+		case len(ports) > 1:
+			port = ports[i]
+		}
+
+		if port != "" {
+			p, err := strconv.ParseUint(port, 10, 16)
+			if err != nil {
+				return DSNConfig{}, fmt.Errorf("invalid port %s: %w", port, err)
+			}
+			h.Port = uint16(p)
+		}
+
+		cfg.Hosts = append(cfg.Hosts, h)
+	}
+
+	if len(hosts) == 0 && len(ports) == 1 {
+		p, err := strconv.ParseUint(ports[0], 10, 16)
+		if err != nil {
+			return DSNConfig{}, fmt.Errorf("invalid port %s: %w", ports[0], err)
+		}
+		cfg.Hosts = []DSNHost{{Port: uint16(p)}}
+	}
+
+	if v := settings[dsnKeyStatementTimeout]; v != "" {
+		timeout, err := parseStatementTimeout(v)
+		if err != nil {
+			return DSNConfig{}, err
+		}
+		cfg.StatementTimeout = timeout
+	}
+
+	if v := settings[dsnKeySearchPath]; v != "" {
+		cfg.SearchPath = splitIdentifiers(v, ',')
+	}
+
+	fields := map[string]*string{
+		dsnKeyDatabase:        &cfg.Database,
+		dsnKeyUser:            &cfg.User,
+		dsnKeyPassword:        &cfg.Password,
+		dsnKeyPassfile:        &cfg.Passfile,
+		dsnKeySSLMode:         &cfg.SSLMode,
+		dsnKeySSLCert:         &cfg.SSLCert,
+		dsnKeySSLKey:          &cfg.SSLKey,
+		dsnKeySSLRootCert:     &cfg.SSLRootCert,
+		dsnKeyApplicationName: &cfg.ApplicationName,
+	}
+
+	for k, v := range settings {
+		if dst, ok := fields[k]; ok {
+			*dst = v
+
+			continue
+		}
+
+		switch k {
+		case dsnKeyHost, dsnKeyPort, dsnKeyStatementTimeout, dsnKeySearchPath:
+			continue
+		}
+
+		if cfg.Params == nil {
+			cfg.Params = make(map[string]string)
+		}
+		cfg.Params[k] = v
+	}
+
+	return cfg, nil
+}
+
+// parseStatementTimeout parses the timeout in milliseconds or as Go duration.
+func parseStatementTimeout(v string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err == nil {
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid statement timeout %s", v)
+	}
+
+	return d, nil
+}
+
+func parseURLSettings(dsn string) (map[string]string, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dsn url: %w", err)
+	}
+
+	settings := make(map[string]string)
+
+	if u.User != nil {
+		settings[dsnKeyUser] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			settings[dsnKeyPassword] = password
+		}
+	}
+
+	var hosts, ports []string
+	for _, hostPort := range strings.Split(u.Host, ",") {
+		if hostPort == "" {
+			continue
+		}
+
+		host, port, err := net.SplitHostPort(hostPort)
+		if err != nil {
+			host, port = strings.Trim(hostPort, "[]"), ""
+		}
+		hosts = append(hosts, host)
+		ports = append(ports, port)
+	}
+
+	if len(hosts) > 0 {
+		settings[dsnKeyHost] = strings.Join(hosts, ",")
+		settings[dsnKeyPort] = strings.Join(ports, ",")
+		if strings.Trim(settings[dsnKeyPort], ",") == "" {
+			delete(settings, dsnKeyPort)
+		}
+	}
+
+	if database := strings.TrimPrefix(u.Path, "/"); database != "" {
+		settings[dsnKeyDatabase] = database
+	}
+
+	for k, v := range u.Query() {
+		settings[k] = v[0]
+	}
+
+	return settings, nil
+}
+
+// parseKeywordValueSettings parses the keyword/value connection string.
+// Values can be single-quoted, backslash escapes quotes and backslashes.
+func parseKeywordValueSettings(dsn string) (map[string]string, error) {
+	settings := make(map[string]string)
+
+	s := strings.TrimSpace(dsn)
+	for len(s) > 0 {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("invalid dsn: missing = after %s", s)
+		}
+
+		key := strings.TrimSpace(s[:eq])
+		if key == "" || strings.ContainsAny(key, " \t\n") {
+			return nil, fmt.Errorf("invalid dsn: invalid keyword %q", key)
+		}
+
+		s = strings.TrimLeft(s[eq+1:], " \t\n")
+
+		var value strings.Builder
+		quoted := strings.HasPrefix(s, "'")
+		if quoted {
+			s = s[1:]
+		}
+
+		i := 0
+		closed := false
+		for ; i < len(s); i++ {
+			c := s[i]
+			if c == '\\' && i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+
+				continue
+			}
+
+			if quoted && c == '\'' {
+				closed = true
+				i++
+
+				break
+			}
+
+			if !quoted && (c == ' ' || c == '\t' || c == '\n') {
+				break
+			}
+
+			value.WriteByte(c)
+		}
+
+		if quoted && !closed {
+			return nil, fmt.Errorf("invalid dsn: unterminated quoted value of %s", key)
+		}
+
+		settings[key] = value.String()
+		s = strings.TrimLeft(s[i:], " \t\n")
+	}
+
+	return settings, nil
+}
+
+func quoteDSNValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`+"\t\n") {
+		return v
+	}
+
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+
+	return "'" + v + "'"
+}
+
+func splitDSNList(v string) []string {
+	if v == "" {
+		return nil
+	}
+
+	return strings.Split(v, ",")
+}
+
+// splitPassfileLine splits the passfile line by colons.
+// Backslash escapes colons and backslashes.
+func splitPassfileLine(line string) []string {
+	var (
+		fields []string
+		field  strings.Builder
+	)
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			field.WriteByte(line[i])
+		case line[i] == ':':
+			fields = append(fields, field.String())
+			field.Reset()
+		default:
+			field.WriteByte(line[i])
+		}
+	}
+
+	return append(fields, field.String())
+}
+
+func passfileFieldMatches(pattern, value string) bool {
+	return pattern == "*" || pattern == value
+}
@@ -0,0 +1,81 @@
+package pkgpostgres_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDSNConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := pkgpostgres.DSNConfig{
+		Hosts:            []pkgpostgres.DSNHost{{Host: "db1"}, {Host: "db2", Port: 5433}},
+		Database:         "app",
+		User:             "app",
+		Password:         "p@ss word",
+		SSLMode:          "verify-full",
+		SSLRootCert:      "/etc/ssl/root.crt",
+		ApplicationName:  "api",
+		StatementTimeout: time.Second * 5,
+		SearchPath:       []string{"app", "Tenant, One"},
+		Params:           map[string]string{"connect_timeout": "10"},
+	}
+
+	for _, dsn := range []string{cfg.URL(), cfg.KeywordValue()} {
+		parsed, err := pkgpostgres.ParseDSN(dsn)
+		require.NoError(t, err, dsn)
+		assert.Equal(t, cfg.Hosts[1], parsed.Hosts[1])
+		assert.Equal(t, uint16(pkgpostgres.DefaultPort), parsed.Hosts[0].Port)
+		assert.Equal(t, cfg.Password, parsed.Password)
+		assert.Equal(t, cfg.StatementTimeout, parsed.StatementTimeout)
+		assert.Equal(t, cfg.SearchPath, parsed.SearchPath)
+		assert.Equal(t, cfg.Params, parsed.Params)
+		assert.Equal(t, cfg.SSLRootCert, parsed.SSLRootCert)
+	}
+
+	// The DSN encodes the settings the same way as the session settings.
+	assert.Contains(t, cfg.KeywordValue(), `search_path='"app", "Tenant, One"'`)
+	assert.Contains(t, cfg.KeywordValue(), "statement_timeout=5000")
+
+	parsed, err := pkgpostgres.ParseDSN("host=localhost search_path=app,public")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app", "public"}, parsed.SearchPath)
+
+	assert.NotContains(t, cfg.Redacted(), "p%40ss")
+	assert.NotContains(t, cfg.String(), "p%40ss")
+	assert.NotContains(t, pkgpostgres.RedactDSN(cfg.KeywordValue()), "p@ss")
+	assert.Equal(t, "host=localhost password=xxxxx port=5432 user=app", pkgpostgres.RedactDSN("host=localhost user=app password='secret'"))
+	assert.Equal(t, "[invalid dsn]", pkgpostgres.RedactDSN("password='secret"))
+
+	// Other secrets are redacted together with the password.
+	withSSLPassword, err := pkgpostgres.ParseDSN("postgres://app@localhost/app?sslpassword=key-secret")
+	require.NoError(t, err)
+	assert.Equal(t, "key-secret", withSSLPassword.Params["sslpassword"])
+	assert.Contains(t, withSSLPassword.URL(), "sslpassword=key-secret")
+	assert.NotContains(t, withSSLPassword.Redacted(), "key-secret")
+	assert.Equal(t, "host=localhost port=5432 sslpassword=xxxxx user=app", pkgpostgres.RedactDSN("host=localhost user=app sslpassword=key-secret"))
+	assert.Equal(t, "postgres://app@localhost:5432/app?sslpassword=xxxxx", pkgpostgres.RedactDSN("postgres://app@localhost/app?sslpassword=key-secret"))
+}
+
+func TestDSNConfig_WithPassfile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "pgpass")
+	err := os.WriteFile(path, []byte("# comment\nother:*:*:*:wrong\ndb1:5432:app:app:se\\:cret\n"), 0o600)
+	require.NoError(t, err)
+
+	cfg, err := pkgpostgres.DSNConfig{
+		Hosts:    []pkgpostgres.DSNHost{{Host: "db1"}},
+		Database: "app",
+		User:     "app",
+		Passfile: path,
+	}.WithPassfile()
+	require.NoError(t, err)
+	assert.Equal(t, "se:cret", cfg.Password)
+}
@@ -0,0 +1,59 @@
+package pkgpostgres
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Error contains the details of an error returned by Postgres.
+// Its fields don't depend on the driver used to connect to the database.
+type Error struct {
+	Code           ErrorCode
+	Severity       string
+	Message        string
+	Detail         string
+	Hint           string
+	SchemaName     string
+	TableName      string
+	ColumnName     string
+	DataTypeName   string
+	ConstraintName string
+	Where          string
+
+	err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the original driver error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// AsError finds the first Postgres error in the err's chain and
+// returns its details.
+func AsError(err error) (*Error, bool) {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil, false
+	}
+
+	return &Error{
+		Code:           ErrorCode(pgErr.Code),
+		Severity:       pgErr.Severity,
+		Message:        pgErr.Message,
+		Detail:         pgErr.Detail,
+		Hint:           pgErr.Hint,
+		SchemaName:     pgErr.SchemaName,
+		TableName:      pgErr.TableName,
+		ColumnName:     pgErr.ColumnName,
+		DataTypeName:   pgErr.DataTypeName,
+		ConstraintName: pgErr.ConstraintName,
+		Where:          pgErr.Where,
+		err:            pgErr,
+	}, true
+}
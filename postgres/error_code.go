@@ -1,3 +1,5 @@
+// Code generated by generrcodes from errcodes.txt. DO NOT EDIT.
+
 package pkgpostgres
 
 const (
@@ -262,3 +264,524 @@ const (
 	ErrXX001 ErrorCode = "XX001" // DataCorrupted
 	ErrXX002 ErrorCode = "XX002" // IndexCorrupted
 )
+
+var errorCodeNames = map[ErrorCode]string{
+	Err00000: "successful_completion",
+	Err01000: "warning",
+	Err0100C: "dynamic_result_sets_returned",
+	Err01008: "implicit_zero_bit_padding",
+	Err01003: "null_value_eliminated_in_set_function",
+	Err01007: "privilege_not_granted",
+	Err01006: "privilege_not_revoked",
+	Err01004: "string_data_right_truncation",
+	Err01P01: "deprecated_feature",
+	Err02000: "no_data",
+	Err02001: "no_additional_dynamic_result_sets_returned",
+	Err03000: "sql_statement_not_yet_complete",
+	Err08000: "connection_exception",
+	Err08003: "connection_does_not_exist",
+	Err08006: "connection_failure",
+	Err08001: "sqlclient_unable_to_establish_sqlconnection",
+	Err08004: "sqlserver_rejected_establishment_of_sqlconnection",
+	Err08007: "transaction_resolution_unknown",
+	Err08P01: "protocol_violation",
+	Err09000: "triggered_action_exception",
+	Err0A000: "feature_not_supported",
+	Err0B000: "invalid_transaction_initiation",
+	Err0F000: "locator_exception",
+	Err0F001: "invalid_locator_specification",
+	Err0L000: "invalid_grantor",
+	Err0LP01: "invalid_grant_operation",
+	Err0P000: "invalid_role_specification",
+	Err0Z000: "diagnostics_exception",
+	Err0Z002: "stacked_diagnostics_accessed_without_active_handler",
+	Err20000: "case_not_found",
+	Err21000: "cardinality_violation",
+	Err22000: "data_exception",
+	Err2202E: "array_subscript_error",
+	Err22021: "character_not_in_repertoire",
+	Err22008: "datetime_field_overflow",
+	Err22012: "division_by_zero",
+	Err22005: "error_in_assignment",
+	Err2200B: "escape_character_conflict",
+	Err22022: "indicator_overflow",
+	Err22015: "interval_field_overflow",
+	Err2201E: "invalid_argument_for_logarithm",
+	Err22014: "invalid_argument_for_ntile_function",
+	Err22016: "invalid_argument_for_nth_value_function",
+	Err2201F: "invalid_argument_for_power_function",
+	Err2201G: "invalid_argument_for_width_bucket_function",
+	Err22018: "invalid_character_value_for_cast",
+	Err22007: "invalid_datetime_format",
+	Err22019: "invalid_escape_character",
+	Err2200D: "invalid_escape_octet",
+	Err22025: "invalid_escape_sequence",
+	Err22P06: "nonstandard_use_of_escape_character",
+	Err22010: "invalid_indicator_parameter_value",
+	Err22023: "invalid_parameter_value",
+	Err22013: "invalid_preceding_or_following_size",
+	Err2201B: "invalid_regular_expression",
+	Err2201W: "invalid_row_count_in_limit_clause",
+	Err2201X: "invalid_row_count_in_result_offset_clause",
+	Err2202H: "invalid_tablesample_argument",
+	Err2202G: "invalid_tablesample_repeat",
+	Err22009: "invalid_time_zone_displacement_value",
+	Err2200C: "invalid_use_of_escape_character",
+	Err2200G: "most_specific_type_mismatch",
+	Err22004: "null_value_not_allowed",
+	Err22002: "null_value_no_indicator_parameter",
+	Err22003: "numeric_value_out_of_range",
+	Err2200H: "sequence_generator_limit_exceeded",
+	Err22026: "string_data_length_mismatch",
+	Err22001: "string_data_right_truncation",
+	Err22011: "substring_error",
+	Err22027: "trim_error",
+	Err22024: "unterminated_c_string",
+	Err2200F: "zero_length_character_string",
+	Err22P01: "floating_point_exception",
+	Err22P02: "invalid_text_representation",
+	Err22P03: "invalid_binary_representation",
+	Err22P04: "bad_copy_file_format",
+	Err22P05: "untranslatable_character",
+	Err2200L: "not_an_xml_document",
+	Err2200M: "invalid_xml_document",
+	Err2200N: "invalid_xml_content",
+	Err2200S: "invalid_xml_comment",
+	Err2200T: "invalid_xml_processing_instruction",
+	Err22030: "duplicate_json_object_key_value",
+	Err22031: "invalid_argument_for_sql_json_datetime_function",
+	Err22032: "invalid_json_text",
+	Err22033: "invalid_sql_json_subscript",
+	Err22034: "more_than_one_sql_json_item",
+	Err22035: "no_sql_json_item",
+	Err22036: "non_numeric_sql_json_item",
+	Err22037: "non_unique_keys_in_a_json_object",
+	Err22038: "singleton_sql_json_item_required",
+	Err22039: "sql_json_array_not_found",
+	Err2203A: "sql_json_member_not_found",
+	Err2203B: "sql_json_number_not_found",
+	Err2203C: "sql_json_object_not_found",
+	Err2203D: "too_many_json_array_elements",
+	Err2203E: "too_many_json_object_members",
+	Err2203F: "sql_json_scalar_required",
+	Err2203G: "sql_json_item_cannot_be_cast_to_target_type",
+	Err23000: "integrity_constraint_violation",
+	Err23001: "restrict_violation",
+	Err23502: "not_null_violation",
+	Err23503: "foreign_key_violation",
+	Err23505: "unique_violation",
+	Err23514: "check_violation",
+	Err23P01: "exclusion_violation",
+	Err24000: "invalid_cursor_state",
+	Err25000: "invalid_transaction_state",
+	Err25001: "active_sql_transaction",
+	Err25002: "branch_transaction_already_active",
+	Err25008: "held_cursor_requires_same_isolation_level",
+	Err25003: "inappropriate_access_mode_for_branch_transaction",
+	Err25004: "inappropriate_isolation_level_for_branch_transaction",
+	Err25005: "no_active_sql_transaction_for_branch_transaction",
+	Err25006: "read_only_sql_transaction",
+	Err25007: "schema_and_data_statement_mixing_not_supported",
+	Err25P01: "no_active_sql_transaction",
+	Err25P02: "in_failed_sql_transaction",
+	Err25P03: "idle_in_transaction_session_timeout",
+	Err26000: "invalid_sql_statement_name",
+	Err27000: "triggered_data_change_violation",
+	Err28000: "invalid_authorization_specification",
+	Err28P01: "invalid_password",
+	Err2B000: "dependent_privilege_descriptors_still_exist",
+	Err2BP01: "dependent_objects_still_exist",
+	Err2D000: "invalid_transaction_termination",
+	Err2F000: "sql_routine_exception",
+	Err2F005: "function_executed_no_return_statement",
+	Err2F002: "modifying_sql_data_not_permitted",
+	Err2F003: "prohibited_sql_statement_attempted",
+	Err2F004: "reading_sql_data_not_permitted",
+	Err34000: "invalid_cursor_name",
+	Err38000: "external_routine_exception",
+	Err38001: "containing_sql_not_permitted",
+	Err38002: "modifying_sql_data_not_permitted",
+	Err38003: "prohibited_sql_statement_attempted",
+	Err38004: "reading_sql_data_not_permitted",
+	Err39000: "external_routine_invocation_exception",
+	Err39001: "invalid_sqlstate_returned",
+	Err39004: "null_value_not_allowed",
+	Err39P01: "trigger_protocol_violated",
+	Err39P02: "srf_protocol_violated",
+	Err39P03: "event_trigger_protocol_violated",
+	Err3B000: "savepoint_exception",
+	Err3B001: "invalid_savepoint_specification",
+	Err3D000: "invalid_catalog_name",
+	Err3F000: "invalid_schema_name",
+	Err40000: "transaction_rollback",
+	Err40002: "transaction_integrity_constraint_violation",
+	Err40001: "serialization_failure",
+	Err40003: "statement_completion_unknown",
+	Err40P01: "deadlock_detected",
+	Err42000: "syntax_error_or_access_rule_violation",
+	Err42601: "syntax_error",
+	Err42501: "insufficient_privilege",
+	Err42846: "cannot_coerce",
+	Err42803: "grouping_error",
+	Err42P20: "windowing_error",
+	Err42P19: "invalid_recursion",
+	Err42830: "invalid_foreign_key",
+	Err42602: "invalid_name",
+	Err42622: "name_too_long",
+	Err42939: "reserved_name",
+	Err42804: "datatype_mismatch",
+	Err42P18: "indeterminate_datatype",
+	Err42P21: "collation_mismatch",
+	Err42P22: "indeterminate_collation",
+	Err42809: "wrong_object_type",
+	Err428C9: "generated_always",
+	Err42703: "undefined_column",
+	Err42883: "undefined_function",
+	Err42P01: "undefined_table",
+	Err42P02: "undefined_parameter",
+	Err42704: "undefined_object",
+	Err42701: "duplicate_column",
+	Err42P03: "duplicate_cursor",
+	Err42P04: "duplicate_database",
+	Err42723: "duplicate_function",
+	Err42P05: "duplicate_prepared_statement",
+	Err42P06: "duplicate_schema",
+	Err42P07: "duplicate_table",
+	Err42712: "duplicate_alias",
+	Err42710: "duplicate_object",
+	Err42702: "ambiguous_column",
+	Err42725: "ambiguous_function",
+	Err42P08: "ambiguous_parameter",
+	Err42P09: "ambiguous_alias",
+	Err42P10: "invalid_column_reference",
+	Err42611: "invalid_column_definition",
+	Err42P11: "invalid_cursor_definition",
+	Err42P12: "invalid_database_definition",
+	Err42P13: "invalid_function_definition",
+	Err42P14: "invalid_prepared_statement_definition",
+	Err42P15: "invalid_schema_definition",
+	Err42P16: "invalid_table_definition",
+	Err42P17: "invalid_object_definition",
+	Err44000: "with_check_option_violation",
+	Err53000: "insufficient_resources",
+	Err53100: "disk_full",
+	Err53200: "out_of_memory",
+	Err53300: "too_many_connections",
+	Err53400: "configuration_limit_exceeded",
+	Err54000: "program_limit_exceeded",
+	Err54001: "statement_too_complex",
+	Err54011: "too_many_columns",
+	Err54023: "too_many_arguments",
+	Err55000: "object_not_in_prerequisite_state",
+	Err55006: "object_in_use",
+	Err55P02: "cant_change_runtime_param",
+	Err55P03: "lock_not_available",
+	Err55P04: "unsafe_new_enum_value_usage",
+	Err57000: "operator_intervention",
+	Err57014: "query_canceled",
+	Err57P01: "admin_shutdown",
+	Err57P02: "crash_shutdown",
+	Err57P03: "cannot_connect_now",
+	Err57P04: "database_dropped",
+	Err57P05: "idle_session_timeout",
+	Err58000: "system_error",
+	Err58030: "io_error",
+	Err58P01: "undefined_file",
+	Err58P02: "duplicate_file",
+	Err72000: "snapshot_too_old",
+	ErrF0000: "config_file_error",
+	ErrF0001: "lock_file_exists",
+	ErrHV000: "fdw_error",
+	ErrHV005: "fdw_column_name_not_found",
+	ErrHV002: "fdw_dynamic_parameter_value_needed",
+	ErrHV010: "fdw_function_sequence_error",
+	ErrHV021: "fdw_inconsistent_descriptor_information",
+	ErrHV024: "fdw_invalid_attribute_value",
+	ErrHV007: "fdw_invalid_column_name",
+	ErrHV008: "fdw_invalid_column_number",
+	ErrHV004: "fdw_invalid_data_type",
+	ErrHV006: "fdw_invalid_data_type_descriptors",
+	ErrHV091: "fdw_invalid_descriptor_field_identifier",
+	ErrHV00B: "fdw_invalid_handle",
+	ErrHV00C: "fdw_invalid_option_index",
+	ErrHV00D: "fdw_invalid_option_name",
+	ErrHV090: "fdw_invalid_string_length_or_buffer_length",
+	ErrHV00A: "fdw_invalid_string_format",
+	ErrHV009: "fdw_invalid_use_of_null_pointer",
+	ErrHV014: "fdw_too_many_handles",
+	ErrHV001: "fdw_out_of_memory",
+	ErrHV00P: "fdw_no_schemas",
+	ErrHV00J: "fdw_option_name_not_found",
+	ErrHV00K: "fdw_reply_handle",
+	ErrHV00Q: "fdw_schema_not_found",
+	ErrHV00R: "fdw_table_not_found",
+	ErrHV00L: "fdw_unable_to_create_execution",
+	ErrHV00M: "fdw_unable_to_create_reply",
+	ErrHV00N: "fdw_unable_to_establish_connection",
+	ErrP0000: "plpgsql_error",
+	ErrP0001: "raise_exception",
+	ErrP0002: "no_data_found",
+	ErrP0003: "too_many_rows",
+	ErrP0004: "assert_failure",
+	ErrXX000: "internal_error",
+	ErrXX001: "data_corrupted",
+	ErrXX002: "index_corrupted",
+}
+
+var errorCodesByName = map[string]ErrorCode{
+	"successful_completion":                                Err00000,
+	"warning":                                              Err01000,
+	"dynamic_result_sets_returned":                         Err0100C,
+	"implicit_zero_bit_padding":                            Err01008,
+	"null_value_eliminated_in_set_function":                Err01003,
+	"privilege_not_granted":                                Err01007,
+	"privilege_not_revoked":                                Err01006,
+	"string_data_right_truncation":                         Err22001,
+	"deprecated_feature":                                   Err01P01,
+	"no_data":                                              Err02000,
+	"no_additional_dynamic_result_sets_returned":           Err02001,
+	"sql_statement_not_yet_complete":                       Err03000,
+	"connection_exception":                                 Err08000,
+	"connection_does_not_exist":                            Err08003,
+	"connection_failure":                                   Err08006,
+	"sqlclient_unable_to_establish_sqlconnection":          Err08001,
+	"sqlserver_rejected_establishment_of_sqlconnection":    Err08004,
+	"transaction_resolution_unknown":                       Err08007,
+	"protocol_violation":                                   Err08P01,
+	"triggered_action_exception":                           Err09000,
+	"feature_not_supported":                                Err0A000,
+	"invalid_transaction_initiation":                       Err0B000,
+	"locator_exception":                                    Err0F000,
+	"invalid_locator_specification":                        Err0F001,
+	"invalid_grantor":                                      Err0L000,
+	"invalid_grant_operation":                              Err0LP01,
+	"invalid_role_specification":                           Err0P000,
+	"diagnostics_exception":                                Err0Z000,
+	"stacked_diagnostics_accessed_without_active_handler":  Err0Z002,
+	"case_not_found":                                       Err20000,
+	"cardinality_violation":                                Err21000,
+	"data_exception":                                       Err22000,
+	"array_subscript_error":                                Err2202E,
+	"character_not_in_repertoire":                          Err22021,
+	"datetime_field_overflow":                              Err22008,
+	"division_by_zero":                                     Err22012,
+	"error_in_assignment":                                  Err22005,
+	"escape_character_conflict":                            Err2200B,
+	"indicator_overflow":                                   Err22022,
+	"interval_field_overflow":                              Err22015,
+	"invalid_argument_for_logarithm":                       Err2201E,
+	"invalid_argument_for_ntile_function":                  Err22014,
+	"invalid_argument_for_nth_value_function":              Err22016,
+	"invalid_argument_for_power_function":                  Err2201F,
+	"invalid_argument_for_width_bucket_function":           Err2201G,
+	"invalid_character_value_for_cast":                     Err22018,
+	"invalid_datetime_format":                              Err22007,
+	"invalid_escape_character":                             Err22019,
+	"invalid_escape_octet":                                 Err2200D,
+	"invalid_escape_sequence":                              Err22025,
+	"nonstandard_use_of_escape_character":                  Err22P06,
+	"invalid_indicator_parameter_value":                    Err22010,
+	"invalid_parameter_value":                              Err22023,
+	"invalid_preceding_or_following_size":                  Err22013,
+	"invalid_regular_expression":                           Err2201B,
+	"invalid_row_count_in_limit_clause":                    Err2201W,
+	"invalid_row_count_in_result_offset_clause":            Err2201X,
+	"invalid_tablesample_argument":                         Err2202H,
+	"invalid_tablesample_repeat":                           Err2202G,
+	"invalid_time_zone_displacement_value":                 Err22009,
+	"invalid_use_of_escape_character":                      Err2200C,
+	"most_specific_type_mismatch":                          Err2200G,
+	"null_value_not_allowed":                               Err22004,
+	"null_value_no_indicator_parameter":                    Err22002,
+	"numeric_value_out_of_range":                           Err22003,
+	"sequence_generator_limit_exceeded":                    Err2200H,
+	"string_data_length_mismatch":                          Err22026,
+	"substring_error":                                      Err22011,
+	"trim_error":                                           Err22027,
+	"unterminated_c_string":                                Err22024,
+	"zero_length_character_string":                         Err2200F,
+	"floating_point_exception":                             Err22P01,
+	"invalid_text_representation":                          Err22P02,
+	"invalid_binary_representation":                        Err22P03,
+	"bad_copy_file_format":                                 Err22P04,
+	"untranslatable_character":                             Err22P05,
+	"not_an_xml_document":                                  Err2200L,
+	"invalid_xml_document":                                 Err2200M,
+	"invalid_xml_content":                                  Err2200N,
+	"invalid_xml_comment":                                  Err2200S,
+	"invalid_xml_processing_instruction":                   Err2200T,
+	"duplicate_json_object_key_value":                      Err22030,
+	"invalid_argument_for_sql_json_datetime_function":      Err22031,
+	"invalid_json_text":                                    Err22032,
+	"invalid_sql_json_subscript":                           Err22033,
+	"more_than_one_sql_json_item":                          Err22034,
+	"no_sql_json_item":                                     Err22035,
+	"non_numeric_sql_json_item":                            Err22036,
+	"non_unique_keys_in_a_json_object":                     Err22037,
+	"singleton_sql_json_item_required":                     Err22038,
+	"sql_json_array_not_found":                             Err22039,
+	"sql_json_member_not_found":                            Err2203A,
+	"sql_json_number_not_found":                            Err2203B,
+	"sql_json_object_not_found":                            Err2203C,
+	"too_many_json_array_elements":                         Err2203D,
+	"too_many_json_object_members":                         Err2203E,
+	"sql_json_scalar_required":                             Err2203F,
+	"sql_json_item_cannot_be_cast_to_target_type":          Err2203G,
+	"integrity_constraint_violation":                       Err23000,
+	"restrict_violation":                                   Err23001,
+	"not_null_violation":                                   Err23502,
+	"foreign_key_violation":                                Err23503,
+	"unique_violation":                                     Err23505,
+	"check_violation":                                      Err23514,
+	"exclusion_violation":                                  Err23P01,
+	"invalid_cursor_state":                                 Err24000,
+	"invalid_transaction_state":                            Err25000,
+	"active_sql_transaction":                               Err25001,
+	"branch_transaction_already_active":                    Err25002,
+	"held_cursor_requires_same_isolation_level":            Err25008,
+	"inappropriate_access_mode_for_branch_transaction":     Err25003,
+	"inappropriate_isolation_level_for_branch_transaction": Err25004,
+	"no_active_sql_transaction_for_branch_transaction":     Err25005,
+	"read_only_sql_transaction":                            Err25006,
+	"schema_and_data_statement_mixing_not_supported":       Err25007,
+	"no_active_sql_transaction":                            Err25P01,
+	"in_failed_sql_transaction":                            Err25P02,
+	"idle_in_transaction_session_timeout":                  Err25P03,
+	"invalid_sql_statement_name":                           Err26000,
+	"triggered_data_change_violation":                      Err27000,
+	"invalid_authorization_specification":                  Err28000,
+	"invalid_password":                                     Err28P01,
+	"dependent_privilege_descriptors_still_exist":          Err2B000,
+	"dependent_objects_still_exist":                        Err2BP01,
+	"invalid_transaction_termination":                      Err2D000,
+	"sql_routine_exception":                                Err2F000,
+	"function_executed_no_return_statement":                Err2F005,
+	"modifying_sql_data_not_permitted":                     Err2F002,
+	"prohibited_sql_statement_attempted":                   Err2F003,
+	"reading_sql_data_not_permitted":                       Err2F004,
+	"invalid_cursor_name":                                  Err34000,
+	"external_routine_exception":                           Err38000,
+	"containing_sql_not_permitted":                         Err38001,
+	"external_routine_invocation_exception":                Err39000,
+	"invalid_sqlstate_returned":                            Err39001,
+	"trigger_protocol_violated":                            Err39P01,
+	"srf_protocol_violated":                                Err39P02,
+	"event_trigger_protocol_violated":                      Err39P03,
+	"savepoint_exception":                                  Err3B000,
+	"invalid_savepoint_specification":                      Err3B001,
+	"invalid_catalog_name":                                 Err3D000,
+	"invalid_schema_name":                                  Err3F000,
+	"transaction_rollback":                                 Err40000,
+	"transaction_integrity_constraint_violation":           Err40002,
+	"serialization_failure":                                Err40001,
+	"statement_completion_unknown":                         Err40003,
+	"deadlock_detected":                                    Err40P01,
+	"syntax_error_or_access_rule_violation":                Err42000,
+	"syntax_error":                                         Err42601,
+	"insufficient_privilege":                               Err42501,
+	"cannot_coerce":                                        Err42846,
+	"grouping_error":                                       Err42803,
+	"windowing_error":                                      Err42P20,
+	"invalid_recursion":                                    Err42P19,
+	"invalid_foreign_key":                                  Err42830,
+	"invalid_name":                                         Err42602,
+	"name_too_long":                                        Err42622,
+	"reserved_name":                                        Err42939,
+	"datatype_mismatch":                                    Err42804,
+	"indeterminate_datatype":                               Err42P18,
+	"collation_mismatch":                                   Err42P21,
+	"indeterminate_collation":                              Err42P22,
+	"wrong_object_type":                                    Err42809,
+	"generated_always":                                     Err428C9,
+	"undefined_column":                                     Err42703,
+	"undefined_function":                                   Err42883,
+	"undefined_table":                                      Err42P01,
+	"undefined_parameter":                                  Err42P02,
+	"undefined_object":                                     Err42704,
+	"duplicate_column":                                     Err42701,
+	"duplicate_cursor":                                     Err42P03,
+	"duplicate_database":                                   Err42P04,
+	"duplicate_function":                                   Err42723,
+	"duplicate_prepared_statement":                         Err42P05,
+	"duplicate_schema":                                     Err42P06,
+	"duplicate_table":                                      Err42P07,
+	"duplicate_alias":                                      Err42712,
+	"duplicate_object":                                     Err42710,
+	"ambiguous_column":                                     Err42702,
+	"ambiguous_function":                                   Err42725,
+	"ambiguous_parameter":                                  Err42P08,
+	"ambiguous_alias":                                      Err42P09,
+	"invalid_column_reference":                             Err42P10,
+	"invalid_column_definition":                            Err42611,
+	"invalid_cursor_definition":                            Err42P11,
+	"invalid_database_definition":                          Err42P12,
+	"invalid_function_definition":                          Err42P13,
+	"invalid_prepared_statement_definition":                Err42P14,
+	"invalid_schema_definition":                            Err42P15,
+	"invalid_table_definition":                             Err42P16,
+	"invalid_object_definition":                            Err42P17,
+	"with_check_option_violation":                          Err44000,
+	"insufficient_resources":                               Err53000,
+	"disk_full":                                            Err53100,
+	"out_of_memory":                                        Err53200,
+	"too_many_connections":                                 Err53300,
+	"configuration_limit_exceeded":                         Err53400,
+	"program_limit_exceeded":                               Err54000,
+	"statement_too_complex":                                Err54001,
+	"too_many_columns":                                     Err54011,
+	"too_many_arguments":                                   Err54023,
+	"object_not_in_prerequisite_state":                     Err55000,
+	"object_in_use":                                        Err55006,
+	"cant_change_runtime_param":                            Err55P02,
+	"lock_not_available":                                   Err55P03,
+	"unsafe_new_enum_value_usage":                          Err55P04,
+	"operator_intervention":                                Err57000,
+	"query_canceled":                                       Err57014,
+	"admin_shutdown":                                       Err57P01,
+	"crash_shutdown":                                       Err57P02,
+	"cannot_connect_now":                                   Err57P03,
+	"database_dropped":                                     Err57P04,
+	"idle_session_timeout":                                 Err57P05,
+	"system_error":                                         Err58000,
+	"io_error":                                             Err58030,
+	"undefined_file":                                       Err58P01,
+	"duplicate_file":                                       Err58P02,
+	"snapshot_too_old":                                     Err72000,
+	"config_file_error":                                    ErrF0000,
+	"lock_file_exists":                                     ErrF0001,
+	"fdw_error":                                            ErrHV000,
+	"fdw_column_name_not_found":                            ErrHV005,
+	"fdw_dynamic_parameter_value_needed":                   ErrHV002,
+	"fdw_function_sequence_error":                          ErrHV010,
+	"fdw_inconsistent_descriptor_information":              ErrHV021,
+	"fdw_invalid_attribute_value":                          ErrHV024,
+	"fdw_invalid_column_name":                              ErrHV007,
+	"fdw_invalid_column_number":                            ErrHV008,
+	"fdw_invalid_data_type":                                ErrHV004,
+	"fdw_invalid_data_type_descriptors":                    ErrHV006,
+	"fdw_invalid_descriptor_field_identifier":              ErrHV091,
+	"fdw_invalid_handle":                                   ErrHV00B,
+	"fdw_invalid_option_index":                             ErrHV00C,
+	"fdw_invalid_option_name":                              ErrHV00D,
+	"fdw_invalid_string_length_or_buffer_length":           ErrHV090,
+	"fdw_invalid_string_format":                            ErrHV00A,
+	"fdw_invalid_use_of_null_pointer":                      ErrHV009,
+	"fdw_too_many_handles":                                 ErrHV014,
+	"fdw_out_of_memory":                                    ErrHV001,
+	"fdw_no_schemas":                                       ErrHV00P,
+	"fdw_option_name_not_found":                            ErrHV00J,
+	"fdw_reply_handle":                                     ErrHV00K,
+	"fdw_schema_not_found":                                 ErrHV00Q,
+	"fdw_table_not_found":                                  ErrHV00R,
+	"fdw_unable_to_create_execution":                       ErrHV00L,
+	"fdw_unable_to_create_reply":                           ErrHV00M,
+	"fdw_unable_to_establish_connection":                   ErrHV00N,
+	"plpgsql_error":                                        ErrP0000,
+	"raise_exception":                                      ErrP0001,
+	"no_data_found":                                        ErrP0002,
+	"too_many_rows":                                        ErrP0003,
+	"assert_failure":                                       ErrP0004,
+	"internal_error":                                       ErrXX000,
+	"data_corrupted":                                       ErrXX001,
+	"index_corrupted":                                      ErrXX002,
+}
@@ -0,0 +1,63 @@
+package pkgpostgres
+
+//go:generate go run ../tools/generrcodes -input errcodes.txt -output error_code.go
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidErrorCode is returned by ParseErrorCode for the strings that
+// are neither a known error code nor a condition name.
+var ErrInvalidErrorCode = errors.New("not a valid ErrorCode")
+
+// Name returns the condition name of the error code, e.g. "unique_violation"
+// for "23505". It returns an empty string for unknown codes.
+func (c ErrorCode) Name() string {
+	return errorCodeNames[c]
+}
+
+// Class returns the error code of the class the code belongs to,
+// e.g. "23000" (integrity_constraint_violation) for "23505".
+func (c ErrorCode) Class() ErrorCode {
+	class := ErrorClass(c)
+	if class == "" {
+		return ""
+	}
+
+	return ErrorCode(class + "000")
+}
+
+// IsValid reports whether the code is a known Postgres error code.
+func (c ErrorCode) IsValid() bool {
+	_, ok := errorCodeNames[c]
+
+	return ok
+}
+
+// String implements the Stringer interface.
+// It returns the condition name along with the code, e.g. "unique_violation (23505)".
+func (c ErrorCode) String() string {
+	name := c.Name()
+	if name == "" {
+		return string(c)
+	}
+
+	return fmt.Sprintf("%s (%s)", name, string(c))
+}
+
+// ParseErrorCode converts either the error code, e.g. "23505",
+// or the condition name, e.g. "unique_violation", to ErrorCode.
+func ParseErrorCode(s string) (ErrorCode, error) {
+	code := ErrorCode(strings.ToUpper(s))
+	if code.IsValid() {
+		return code, nil
+	}
+
+	if code, ok := errorCodesByName[strings.ToLower(s)]; ok {
+		return code, nil
+	}
+
+	return "", fmt.Errorf("%s is %w", s, ErrInvalidErrorCode)
+}
@@ -0,0 +1,241 @@
+package pkgpostgres
+
+import (
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// ErrorRule maps Postgres errors to a domain error.
+// Empty Code, Class and Constraint match any value. A rule with all
+// of them empty matches every Postgres error.
+//
+// Example:
+//
+//	ErrorRule{Code: Err23505, Constraint: "users_email_key", Err: ErrEmailTaken}
+type ErrorRule struct {
+	// Code is the error code to match.
+	Code ErrorCode
+	// Class is the error class to match, e.g. "23".
+	Class string
+	// Constraint is the name of the constraint to match.
+	Constraint string
+	// Err is the domain error returned for the matched Postgres error.
+	Err error
+	// HTTPStatus overrides the default HTTP status of the matched error.
+	HTTPStatus int
+	// GRPCCode overrides the default gRPC code of the matched error.
+	GRPCCode *codes.Code
+}
+
+func (r ErrorRule) matches(pgErr *Error) bool {
+	if r.Code != "" && r.Code != pgErr.Code {
+		return false
+	}
+
+	if r.Class != "" && r.Class != ErrorClass(pgErr.Code) {
+		return false
+	}
+
+	if r.Constraint != "" && r.Constraint != pgErr.ConstraintName {
+		return false
+	}
+
+	return true
+}
+
+// specificity returns how specific the rule is. More specific rules
+// take precedence over less specific ones.
+func (r ErrorRule) specificity() int {
+	var res int
+	if r.Constraint != "" {
+		res += 4
+	}
+	if r.Code != "" {
+		res += 2
+	}
+	if r.Class != "" {
+		res++
+	}
+
+	return res
+}
+
+// MappedError is a domain error mapped from a Postgres error.
+// Both the domain error and the original error can be found
+// with errors.Is and errors.As.
+type MappedError struct {
+	Err        error
+	Cause      error
+	PgError    *Error
+	HTTPStatus int
+	GRPCCode   codes.Code
+}
+
+// Error implements the error interface.
+func (e *MappedError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the domain error and the original error.
+func (e *MappedError) Unwrap() []error {
+	return []error{e.Err, e.Cause}
+}
+
+// ErrorMapper maps Postgres errors to domain errors using the registered rules.
+type ErrorMapper struct {
+	rules []ErrorRule
+}
+
+// NewErrorMapper returns a new ErrorMapper with the given rules.
+func NewErrorMapper(rules ...ErrorRule) *ErrorMapper {
+	m := &ErrorMapper{}
+	for _, rule := range rules {
+		m.Register(rule)
+	}
+
+	return m
+}
+
+// Register adds a rule to the mapper. When several rules match an error,
+// the most specific one is used: constraint is more specific than code
+// and code is more specific than class. Among equally specific rules
+// the first registered one wins.
+func (m *ErrorMapper) Register(rule ErrorRule) *ErrorMapper {
+	m.rules = append(m.rules, rule)
+
+	return m
+}
+
+// Map maps the Postgres error to a *MappedError. If no rule matches,
+// the returned *MappedError contains the original error as the domain error
+// and the default HTTP status and gRPC code. Errors that are not Postgres
+// errors are returned as is.
+func (m *ErrorMapper) Map(err error) error {
+	pgErr, ok := AsError(err)
+	if !ok {
+		return err
+	}
+
+	mapped := &MappedError{
+		Err:        err,
+		Cause:      err,
+		PgError:    pgErr,
+		HTTPStatus: DefaultHTTPStatus(pgErr.Code),
+		GRPCCode:   DefaultGRPCCode(pgErr.Code),
+	}
+
+	var rule *ErrorRule
+	for i := range m.rules {
+		if !m.rules[i].matches(pgErr) {
+			continue
+		}
+
+		if rule == nil || m.rules[i].specificity() > rule.specificity() {
+			rule = &m.rules[i]
+		}
+	}
+
+	if rule == nil {
+		return mapped
+	}
+
+	if rule.Err != nil {
+		mapped.Err = rule.Err
+	}
+
+	if rule.HTTPStatus != 0 {
+		mapped.HTTPStatus = rule.HTTPStatus
+	}
+
+	if rule.GRPCCode != nil {
+		mapped.GRPCCode = *rule.GRPCCode
+	}
+
+	return mapped
+}
+
+// HTTPStatus returns the HTTP status of the error. It uses the status of
+// *MappedError if there is one in the err's chain, otherwise the default
+// status of the Postgres error. For other errors it returns 500.
+func HTTPStatus(err error) int {
+	var mapped *MappedError
+	if errors.As(err, &mapped) {
+		return mapped.HTTPStatus
+	}
+
+	if pgErr, ok := AsError(err); ok {
+		return DefaultHTTPStatus(pgErr.Code)
+	}
+
+	return http.StatusInternalServerError
+}
+
+// GRPCCode returns the gRPC code of the error. It uses the code of
+// *MappedError if there is one in the err's chain, otherwise the default
+// code of the Postgres error. For other errors it returns codes.Internal.
+func GRPCCode(err error) codes.Code {
+	var mapped *MappedError
+	if errors.As(err, &mapped) {
+		return mapped.GRPCCode
+	}
+
+	if pgErr, ok := AsError(err); ok {
+		return DefaultGRPCCode(pgErr.Code)
+	}
+
+	return codes.Internal
+}
+
+// DefaultHTTPStatus returns the default HTTP status for the error code.
+func DefaultHTTPStatus(code ErrorCode) int {
+	switch code {
+	case Err23505, Err23P01, Err40001, Err40P01, Err55P03:
+		return http.StatusConflict
+	case Err23503, Err23502, Err23514:
+		return http.StatusUnprocessableEntity
+	case Err42501:
+		return http.StatusForbidden
+	case Err57014:
+		return http.StatusGatewayTimeout
+	case Err53300, Err57P01, Err57P02, Err57P03:
+		return http.StatusServiceUnavailable
+	}
+
+	switch ErrorClass(code) {
+	case ErrorClass(Err22000):
+		return http.StatusBadRequest
+	case ErrorClass(Err08000):
+		return http.StatusServiceUnavailable
+	}
+
+	return http.StatusInternalServerError
+}
+
+// DefaultGRPCCode returns the default gRPC code for the error code.
+func DefaultGRPCCode(code ErrorCode) codes.Code {
+	switch code {
+	case Err23505, Err23P01:
+		return codes.AlreadyExists
+	case Err23503, Err23502, Err23514:
+		return codes.FailedPrecondition
+	case Err40001, Err40P01, Err55P03:
+		return codes.Aborted
+	case Err42501:
+		return codes.PermissionDenied
+	case Err57014:
+		return codes.DeadlineExceeded
+	case Err53300, Err57P01, Err57P02, Err57P03:
+		return codes.Unavailable
+	}
+
+	switch ErrorClass(code) {
+	case ErrorClass(Err22000):
+		return codes.InvalidArgument
+	case ErrorClass(Err08000):
+		return codes.Unavailable
+	}
+
+	return codes.Internal
+}
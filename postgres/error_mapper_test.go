@@ -0,0 +1,60 @@
+package pkgpostgres_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestErrorMapper(t *testing.T) {
+	t.Parallel()
+
+	errEmailTaken := errors.New("email is taken")
+	errConflict := errors.New("conflict")
+	errInvalidData := errors.New("invalid data")
+
+	mapper := pkgpostgres.NewErrorMapper(
+		pkgpostgres.ErrorRule{Class: "22", Err: errInvalidData},
+		pkgpostgres.ErrorRule{Code: pkgpostgres.Err23505, Err: errConflict},
+		pkgpostgres.ErrorRule{Code: pkgpostgres.Err23505, Constraint: "users_email_key", Err: errEmailTaken},
+	)
+
+	t.Run("constraint rule takes precedence", func(t *testing.T) {
+		err := mapper.Map(&pgconn.PgError{Code: "23505", ConstraintName: "users_email_key"})
+		assert.ErrorIs(t, err, errEmailTaken)
+		assert.NotErrorIs(t, err, errConflict)
+		assert.Equal(t, http.StatusConflict, pkgpostgres.HTTPStatus(err))
+		assert.Equal(t, codes.AlreadyExists, pkgpostgres.GRPCCode(err))
+
+		var pgErr *pgconn.PgError
+		assert.ErrorAs(t, err, &pgErr)
+	})
+
+	t.Run("code rule", func(t *testing.T) {
+		err := mapper.Map(&pgconn.PgError{Code: "23505", ConstraintName: "users_pkey"})
+		assert.ErrorIs(t, err, errConflict)
+	})
+
+	t.Run("class rule", func(t *testing.T) {
+		err := mapper.Map(&pgconn.PgError{Code: "22P02"})
+		assert.ErrorIs(t, err, errInvalidData)
+		assert.Equal(t, http.StatusBadRequest, pkgpostgres.HTTPStatus(err))
+	})
+
+	t.Run("no rule", func(t *testing.T) {
+		err := mapper.Map(&pgconn.PgError{Code: "40001"})
+		assert.Equal(t, http.StatusConflict, pkgpostgres.HTTPStatus(err))
+		assert.Equal(t, codes.Aborted, pkgpostgres.GRPCCode(err))
+	})
+
+	t.Run("not a postgres error", func(t *testing.T) {
+		someErr := errors.New("some error")
+		assert.Equal(t, someErr, mapper.Map(someErr))
+		assert.Equal(t, http.StatusInternalServerError, pkgpostgres.HTTPStatus(someErr))
+	})
+}
@@ -0,0 +1,261 @@
+package pkgpostgres
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+)
+
+const (
+	DefaultHealthCheckQuery    = "SELECT 1"
+	DefaultHealthCheckTimeout  = time.Second * 5
+	DefaultHealthCheckInterval = time.Second * 10
+)
+
+// HealthChecker checks whether the database is available.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// HealthCheck runs the probe query with the configured timeout.
+func (s *SQLConn) HealthCheck(ctx context.Context) error {
+	query := s.healthCheckQuery
+	if query == "" {
+		query = DefaultHealthCheckQuery
+	}
+
+	timeout := s.healthCheckTimeout
+	if timeout <= 0 {
+		timeout = DefaultHealthCheckTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := s.DB.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+
+	return nil
+}
+
+// HealthState is a state of the database reported by HealthMonitor.
+type HealthState int
+
+const (
+	HealthStateUnknown HealthState = iota
+	HealthStateHealthy
+	HealthStateUnhealthy
+)
+
+func (s HealthState) String() string {
+	switch s {
+	case HealthStateHealthy:
+		return "healthy"
+	case HealthStateUnhealthy:
+		return "unhealthy"
+	default:
+		return "unknown"
+	}
+}
+
+// HealthMonitorConfig is a configuration of HealthMonitor.
+type HealthMonitorConfig struct {
+	// Interval is the time between two health checks.
+	Interval *time.Duration
+	// OnStateChange is called when the state changes. err is the error
+	// of the failed health check and is nil when the database becomes healthy.
+	OnStateChange func(from, to HealthState, err error)
+}
+
+func (c *HealthMonitorConfig) Validate() error {
+	if c.Interval == nil {
+		c.Interval = pkgptr.Ptr(DefaultHealthCheckInterval)
+	}
+
+	if *c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+
+	return nil
+}
+
+// HealthMonitor periodically checks the database and reports state transitions.
+type HealthMonitor struct {
+	checker HealthChecker
+	cfg     HealthMonitorConfig
+
+	mu      sync.RWMutex
+	state   HealthState
+	lastErr error
+}
+
+// NewHealthMonitor creates a new HealthMonitor. Call Run to start it.
+func NewHealthMonitor(checker HealthChecker, cfg HealthMonitorConfig) (*HealthMonitor, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid HealthMonitor config: %w", err)
+	}
+
+	return &HealthMonitor{
+		checker: checker,
+		cfg:     cfg,
+	}, nil
+}
+
+// Run checks the database immediately and then every interval
+// until the context is canceled.
+func (m *HealthMonitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(*m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *HealthMonitor) check(ctx context.Context) {
+	err := m.checker.HealthCheck(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	state := HealthStateHealthy
+	if err != nil {
+		state = HealthStateUnhealthy
+	}
+
+	m.mu.Lock()
+	prev := m.state
+	m.state = state
+	m.lastErr = err
+	m.mu.Unlock()
+
+	if prev != state && m.cfg.OnStateChange != nil {
+		m.cfg.OnStateChange(prev, state, err)
+	}
+}
+
+// State returns the state of the last health check and its error.
+func (m *HealthMonitor) State() (HealthState, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.state, m.lastErr
+}
+
+// ReplicationRole is a role of the server in replication.
+type ReplicationRole string
+
+const (
+	ReplicationRolePrimary ReplicationRole = "primary"
+	ReplicationRoleReplica ReplicationRole = "replica"
+)
+
+// Stats is a snapshot of the connection pool and server statistics.
+type Stats struct {
+	MaxOpenConnections int
+	OpenConnections    int
+	InUse              int
+	Idle               int
+	WaitCount          int64
+	WaitDuration       time.Duration
+	MaxIdleClosed      int64
+	MaxIdleTimeClosed  int64
+	MaxLifetimeClosed  int64
+	ServerVersion      string
+	ReplicationRole    ReplicationRole
+}
+
+// ServerStats returns the statistics of the connection pool together with
+// the server version and replication role. The pool statistics
+// without querying the server are available with s.Stats().
+func (s *SQLConn) ServerStats(ctx context.Context) (Stats, error) {
+	dbStats := s.DB.Stats()
+
+	res := Stats{
+		MaxOpenConnections: dbStats.MaxOpenConnections,
+		OpenConnections:    dbStats.OpenConnections,
+		InUse:              dbStats.InUse,
+		Idle:               dbStats.Idle,
+		WaitCount:          dbStats.WaitCount,
+		WaitDuration:       dbStats.WaitDuration,
+		MaxIdleClosed:      dbStats.MaxIdleClosed,
+		MaxIdleTimeClosed:  dbStats.MaxIdleTimeClosed,
+		MaxLifetimeClosed:  dbStats.MaxLifetimeClosed,
+	}
+
+	var inRecovery bool
+	err := s.DB.QueryRowContext(ctx, "SELECT current_setting('server_version'), pg_is_in_recovery()").
+		Scan(&res.ServerVersion, &inRecovery)
+	if err != nil {
+		return Stats{}, fmt.Errorf("failed to query server stats: %w", err)
+	}
+
+	res.ReplicationRole = ReplicationRolePrimary
+	if inRecovery {
+		res.ReplicationRole = ReplicationRoleReplica
+	}
+
+	return res, nil
+}
+
+// WritePrometheus writes the stats to w in the Prometheus text exposition
+// format. Every metric name is prefixed with namespace.
+func (s Stats) WritePrometheus(w io.Writer, namespace string) error {
+	if namespace != "" && !strings.HasSuffix(namespace, "_") {
+		namespace += "_"
+	}
+
+	boolToFloat := func(v bool) float64 {
+		if v {
+			return 1
+		}
+
+		return 0
+	}
+
+	metrics := []struct {
+		name   string
+		typ    string
+		help   string
+		labels string
+		value  float64
+	}{
+		{"max_open_connections", "gauge", "Maximum number of open connections to the database.", "", float64(s.MaxOpenConnections)},
+		{"open_connections", "gauge", "The number of established connections both in use and idle.", "", float64(s.OpenConnections)},
+		{"in_use_connections", "gauge", "The number of connections currently in use.", "", float64(s.InUse)},
+		{"idle_connections", "gauge", "The number of idle connections.", "", float64(s.Idle)},
+		{"wait_count_total", "counter", "The total number of connections waited for.", "", float64(s.WaitCount)},
+		{"wait_duration_seconds_total", "counter", "The total time blocked waiting for a new connection.", "", s.WaitDuration.Seconds()},
+		{"max_idle_closed_total", "counter", "The total number of connections closed due to SetMaxIdleConns.", "", float64(s.MaxIdleClosed)},
+		{"max_idle_time_closed_total", "counter", "The total number of connections closed due to SetConnMaxIdleTime.", "", float64(s.MaxIdleTimeClosed)},
+		{"max_lifetime_closed_total", "counter", "The total number of connections closed due to SetConnMaxLifetime.", "", float64(s.MaxLifetimeClosed)},
+		{"server_info", "gauge", "Information about the database server.", fmt.Sprintf(`{version=%q,role=%q}`, s.ServerVersion, s.ReplicationRole), 1},
+		{"in_recovery", "gauge", "Whether the server is a replica in recovery.", "", boolToFloat(s.ReplicationRole == ReplicationRoleReplica)},
+	}
+
+	for _, m := range metrics {
+		name := namespace + m.name
+		_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s%s %s\n",
+			name, m.help, name, m.typ, name, m.labels, strconv.FormatFloat(m.value, 'g', -1, 64))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package pkgpostgres_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type healthCheckerFunc func(ctx context.Context) error
+
+func (f healthCheckerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestHealthMonitor(t *testing.T) {
+	t.Parallel()
+
+	errDown := errors.New("connection refused")
+	results := []error{nil, nil, errDown, errDown, nil}
+
+	type transition struct {
+		from, to pkgpostgres.HealthState
+		err      error
+	}
+
+	var (
+		mu          sync.Mutex
+		calls       int
+		transitions []transition
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	monitor, err := pkgpostgres.NewHealthMonitor(healthCheckerFunc(func(ctx context.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if calls == len(results) {
+			cancel()
+
+			return nil
+		}
+		calls++
+
+		return results[calls-1]
+	}), pkgpostgres.HealthMonitorConfig{
+		Interval: pkgptr.Ptr(time.Millisecond),
+		OnStateChange: func(from, to pkgpostgres.HealthState, err error) {
+			transitions = append(transitions, transition{from, to, err})
+		},
+	})
+	require.NoError(t, err)
+
+	err = monitor.Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+
+	assert.Equal(t, []transition{
+		{pkgpostgres.HealthStateUnknown, pkgpostgres.HealthStateHealthy, nil},
+		{pkgpostgres.HealthStateHealthy, pkgpostgres.HealthStateUnhealthy, errDown},
+		{pkgpostgres.HealthStateUnhealthy, pkgpostgres.HealthStateHealthy, nil},
+	}, transitions)
+
+	state, err := monitor.State()
+	assert.Equal(t, pkgpostgres.HealthStateHealthy, state)
+	assert.NoError(t, err)
+}
+
+func TestStats_WritePrometheus(t *testing.T) {
+	t.Parallel()
+
+	stats := pkgpostgres.Stats{
+		MaxOpenConnections: 10,
+		OpenConnections:    3,
+		InUse:              1,
+		Idle:               2,
+		WaitCount:          4,
+		WaitDuration:       1500 * time.Millisecond,
+		MaxIdleClosed:      5,
+		MaxIdleTimeClosed:  6,
+		MaxLifetimeClosed:  7,
+		ServerVersion:      "16.2",
+		ReplicationRole:    pkgpostgres.ReplicationRoleReplica,
+	}
+
+	var buf bytes.Buffer
+	err := stats.WritePrometheus(&buf, "app_db")
+	require.NoError(t, err)
+
+	var samples []string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			samples = append(samples, line)
+		}
+	}
+	assert.Equal(t, []string{
+		"app_db_max_open_connections 10",
+		"app_db_open_connections 3",
+		"app_db_in_use_connections 1",
+		"app_db_idle_connections 2",
+		"app_db_wait_count_total 4",
+		"app_db_wait_duration_seconds_total 1.5",
+		"app_db_max_idle_closed_total 5",
+		"app_db_max_idle_time_closed_total 6",
+		"app_db_max_lifetime_closed_total 7",
+		`app_db_server_info{version="16.2",role="replica"} 1`,
+		"app_db_in_recovery 1",
+	}, samples)
+	assert.Contains(t, buf.String(), "# TYPE app_db_open_connections gauge\n")
+	assert.Contains(t, buf.String(), "# TYPE app_db_wait_duration_seconds_total counter\n")
+}
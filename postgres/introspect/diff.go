@@ -0,0 +1,168 @@
+package pkgintrospect
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChangeKind is the kind of the difference between two schemas.
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// ObjectType is the type of the changed object.
+type ObjectType string
+
+const (
+	ObjectTable      ObjectType = "table"
+	ObjectColumn     ObjectType = "column"
+	ObjectIndex      ObjectType = "index"
+	ObjectConstraint ObjectType = "constraint"
+	ObjectView       ObjectType = "view"
+	ObjectEnum       ObjectType = "enum"
+)
+
+// Change is a difference between two schemas.
+type Change struct {
+	Kind   ChangeKind
+	Object ObjectType
+	// Name is the qualified name of the object, e.g. public.users.email
+	// for a column.
+	Name string
+	// From describes the object in the first schema, empty if it was added.
+	From string
+	// To describes the object in the second schema, empty if it was removed.
+	To string
+}
+
+func (c Change) String() string {
+	s := fmt.Sprintf("%s %s %s", c.Kind, c.Object, c.Name)
+
+	switch {
+	case c.Kind == ChangeChanged:
+		return fmt.Sprintf("%s: %s -> %s", s, c.From, c.To)
+	case c.From != "":
+		return s + ": " + c.From
+	case c.To != "":
+		return s + ": " + c.To
+	}
+
+	return s
+}
+
+// Diff returns the changes that turn the schema from into the schema to,
+// sorted by the object name. The objects of the removed and added tables
+// are not reported separately.
+func Diff(from, to *Schema) []Change {
+	var changes []Change
+
+	fromTables := make(map[string]Table, len(from.Tables))
+	for _, t := range from.Tables {
+		fromTables[t.QualifiedName()] = t
+	}
+
+	toTables := make(map[string]Table, len(to.Tables))
+	for _, t := range to.Tables {
+		toTables[t.QualifiedName()] = t
+	}
+
+	diffObjects(&changes, ObjectTable, fromTables, toTables, func(Table) string { return "" })
+
+	for name, ft := range fromTables {
+		tt, ok := toTables[name]
+		if !ok {
+			continue
+		}
+
+		diffObjects(&changes, ObjectColumn, byName(name, ft.Columns, func(c Column) string { return c.Name }),
+			byName(name, tt.Columns, func(c Column) string { return c.Name }), describeColumn)
+		diffObjects(&changes, ObjectIndex, byName(name, ft.Indexes, func(i Index) string { return i.Name }),
+			byName(name, tt.Indexes, func(i Index) string { return i.Name }), func(i Index) string { return i.Definition })
+		diffObjects(&changes, ObjectConstraint, byName(name, ft.Constraints, func(c Constraint) string { return c.Name }),
+			byName(name, tt.Constraints, func(c Constraint) string { return c.Name }), func(c Constraint) string { return c.Definition })
+	}
+
+	diffObjects(&changes, ObjectView, byName("", from.Views, View.QualifiedName),
+		byName("", to.Views, View.QualifiedName), describeView)
+	diffObjects(&changes, ObjectEnum, byName("", from.Enums, Enum.QualifiedName),
+		byName("", to.Enums, Enum.QualifiedName), func(e Enum) string { return strings.Join(e.Values, ", ") })
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+
+		return changes[i].Object < changes[j].Object
+	})
+
+	return changes
+}
+
+// diffObjects appends the changes between the objects with the same names.
+// The objects are compared by their descriptions.
+func diffObjects[T any](changes *[]Change, object ObjectType, from, to map[string]T, describe func(T) string) {
+	for name, f := range from {
+		t, ok := to[name]
+		if !ok {
+			*changes = append(*changes, Change{Kind: ChangeRemoved, Object: object, Name: name, From: describe(f)})
+
+			continue
+		}
+
+		if fd, td := describe(f), describe(t); fd != td {
+			*changes = append(*changes, Change{Kind: ChangeChanged, Object: object, Name: name, From: fd, To: td})
+		}
+	}
+
+	for name, t := range to {
+		if _, ok := from[name]; !ok {
+			*changes = append(*changes, Change{Kind: ChangeAdded, Object: object, Name: name, To: describe(t)})
+		}
+	}
+}
+
+// byName indexes the objects by their names qualified with the prefix.
+func byName[T any](prefix string, objects []T, name func(T) string) map[string]T {
+	m := make(map[string]T, len(objects))
+	for _, o := range objects {
+		if prefix == "" {
+			m[name(o)] = o
+		} else {
+			m[prefix+"."+name(o)] = o
+		}
+	}
+
+	return m
+}
+
+func describeColumn(c Column) string {
+	var sb strings.Builder
+	sb.WriteString(c.Type)
+
+	if !c.Nullable {
+		sb.WriteString(" NOT NULL")
+	}
+
+	if c.Default != nil {
+		sb.WriteString(" DEFAULT " + *c.Default)
+	}
+
+	if c.Identity != "" {
+		sb.WriteString(" GENERATED " + c.Identity + " AS IDENTITY")
+	}
+
+	return sb.String()
+}
+
+func describeView(v View) string {
+	if v.Materialized {
+		return "MATERIALIZED " + v.Definition
+	}
+
+	return v.Definition
+}
@@ -0,0 +1,322 @@
+// Package pkgintrospect reads the schema of a Postgres database from
+// pg_catalog and compares schemas, e.g. to detect the drift between the
+// migrated test database and production.
+//
+// Example:
+//
+//	want, err := pkgintrospect.Inspect(ctx, testDB, pkgintrospect.Options{})
+//	got, err := pkgintrospect.Inspect(ctx, prodDB, pkgintrospect.Options{})
+//
+//	for _, change := range pkgintrospect.Diff(want, got) {
+//	    slog.Warn("schema drift", slog.String("change", change.String()))
+//	}
+package pkgintrospect
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+// Schema is the set of database objects.
+type Schema struct {
+	Tables []Table
+	Views  []View
+	Enums  []Enum
+}
+
+// Table is an ordinary or partitioned table. Partitions are omitted.
+type Table struct {
+	Schema      string
+	Name        string
+	Columns     []Column
+	Indexes     []Index
+	Constraints []Constraint
+}
+
+// QualifiedName returns the name of the table qualified with its schema.
+func (t Table) QualifiedName() string {
+	return t.Schema + "." + t.Name
+}
+
+type Column struct {
+	Name string
+	// Position is the ordinal position of the column starting from 1.
+	Position int
+	// Type is the type with its modifiers, e.g. numeric(10,2).
+	Type     string
+	Nullable bool
+	// Default is the default expression, nil if there is none.
+	Default *string
+	// Identity is ALWAYS or BY DEFAULT for identity columns, otherwise empty.
+	Identity string
+}
+
+type Index struct {
+	Name string
+	// Definition is the CREATE INDEX statement of the index.
+	Definition string
+	Unique     bool
+	Primary    bool
+}
+
+// ConstraintType is the type of the table constraint.
+type ConstraintType string
+
+const (
+	ConstraintPrimaryKey ConstraintType = "PRIMARY KEY"
+	ConstraintUnique     ConstraintType = "UNIQUE"
+	ConstraintForeignKey ConstraintType = "FOREIGN KEY"
+	ConstraintCheck      ConstraintType = "CHECK"
+	ConstraintExclusion  ConstraintType = "EXCLUDE"
+)
+
+type Constraint struct {
+	Name string
+	Type ConstraintType
+	// Definition is the constraint definition, e.g. CHECK ((amount > 0)).
+	Definition string
+}
+
+type View struct {
+	Schema       string
+	Name         string
+	Materialized bool
+	// Definition is the query of the view.
+	Definition string
+}
+
+// QualifiedName returns the name of the view qualified with its schema.
+func (v View) QualifiedName() string {
+	return v.Schema + "." + v.Name
+}
+
+type Enum struct {
+	Schema string
+	Name   string
+	// Values are the labels in the sort order.
+	Values []string
+}
+
+// QualifiedName returns the name of the enum qualified with its schema.
+func (e Enum) QualifiedName() string {
+	return e.Schema + "." + e.Name
+}
+
+type Options struct {
+	// Schemas are the schemas to inspect. All the schemas except the
+	// system ones are inspected if it is empty.
+	Schemas []string
+	// ExcludeTables are the tables to omit, qualified or not, e.g. the
+	// tables of the migration tools like schema_migrations.
+	ExcludeTables []string
+}
+
+// schemaFilter matches n.nspname against the schemas passed as $1.
+const schemaFilter = `
+	(cardinality($1::text[]) = 0
+		AND n.nspname NOT IN ('pg_catalog', 'information_schema')
+		AND n.nspname NOT LIKE 'pg\_%'
+	 OR n.nspname = ANY($1::text[]))`
+
+// Inspect reads the schema of the database. The objects are sorted by
+// their schema and name, the columns by their position.
+func Inspect(ctx context.Context, q pkgsql.Querier, opts Options) (*Schema, error) {
+	schemas := opts.Schemas
+	if schemas == nil {
+		schemas = []string{}
+	}
+
+	excluded := make(map[string]struct{}, len(opts.ExcludeTables))
+	for _, name := range opts.ExcludeTables {
+		excluded[name] = struct{}{}
+	}
+
+	isExcluded := func(schema, name string) bool {
+		_, bare := excluded[name]
+		_, qualified := excluded[schema+"."+name]
+
+		return bare || qualified
+	}
+
+	var (
+		schema Schema
+		tables = make(map[string]*Table)
+	)
+
+	err := query(ctx, q, `
+		SELECT n.nspname, c.relname
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('r', 'p') AND NOT c.relispartition AND `+schemaFilter+`
+		ORDER BY 1, 2`, schemas, func(rows *sql.Rows) error {
+		var t Table
+		err := rows.Scan(&t.Schema, &t.Name)
+		if err != nil || isExcluded(t.Schema, t.Name) {
+			return err
+		}
+		schema.Tables = append(schema.Tables, t)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+
+	for i := range schema.Tables {
+		tables[schema.Tables[i].QualifiedName()] = &schema.Tables[i]
+	}
+
+	err = query(ctx, q, `
+		SELECT n.nspname, c.relname, a.attname, a.attnum, format_type(a.atttypid, a.atttypmod),
+			NOT a.attnotnull, pg_get_expr(d.adbin, d.adrelid),
+			CASE a.attidentity WHEN 'a' THEN 'ALWAYS' WHEN 'd' THEN 'BY DEFAULT' ELSE '' END
+		FROM pg_attribute a
+		JOIN pg_class c ON c.oid = a.attrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attnum > 0 AND NOT a.attisdropped AND c.relkind IN ('r', 'p') AND `+schemaFilter+`
+		ORDER BY 1, 2, 4`, schemas, func(rows *sql.Rows) error {
+		var (
+			tableSchema, tableName string
+			c                      Column
+		)
+		err := rows.Scan(&tableSchema, &tableName, &c.Name, &c.Position, &c.Type, &c.Nullable, &c.Default, &c.Identity)
+		if err != nil {
+			return err
+		}
+
+		if t, ok := tables[tableSchema+"."+tableName]; ok {
+			t.Columns = append(t.Columns, c)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	err = query(ctx, q, `
+		SELECT n.nspname, t.relname, i.relname, pg_get_indexdef(ix.indexrelid), ix.indisunique, ix.indisprimary
+		FROM pg_index ix
+		JOIN pg_class i ON i.oid = ix.indexrelid
+		JOIN pg_class t ON t.oid = ix.indrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE t.relkind IN ('r', 'p') AND `+schemaFilter+`
+		ORDER BY 1, 2, 3`, schemas, func(rows *sql.Rows) error {
+		var (
+			tableSchema, tableName string
+			idx                    Index
+		)
+		err := rows.Scan(&tableSchema, &tableName, &idx.Name, &idx.Definition, &idx.Unique, &idx.Primary)
+		if err != nil {
+			return err
+		}
+
+		if t, ok := tables[tableSchema+"."+tableName]; ok {
+			t.Indexes = append(t.Indexes, idx)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+
+	err = query(ctx, q, `
+		SELECT n.nspname, t.relname, con.conname,
+			CASE con.contype
+				WHEN 'p' THEN 'PRIMARY KEY' WHEN 'u' THEN 'UNIQUE' WHEN 'f' THEN 'FOREIGN KEY'
+				WHEN 'c' THEN 'CHECK' WHEN 'x' THEN 'EXCLUDE'
+			END,
+			pg_get_constraintdef(con.oid)
+		FROM pg_constraint con
+		JOIN pg_class t ON t.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = t.relnamespace
+		WHERE con.contype IN ('p', 'u', 'f', 'c', 'x') AND `+schemaFilter+`
+		ORDER BY 1, 2, 3`, schemas, func(rows *sql.Rows) error {
+		var (
+			tableSchema, tableName string
+			con                    Constraint
+		)
+		err := rows.Scan(&tableSchema, &tableName, &con.Name, &con.Type, &con.Definition)
+		if err != nil {
+			return err
+		}
+
+		if t, ok := tables[tableSchema+"."+tableName]; ok {
+			t.Constraints = append(t.Constraints, con)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+
+	err = query(ctx, q, `
+		SELECT n.nspname, c.relname, c.relkind = 'm', pg_get_viewdef(c.oid, true)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('v', 'm') AND `+schemaFilter+`
+		ORDER BY 1, 2`, schemas, func(rows *sql.Rows) error {
+		var v View
+		err := rows.Scan(&v.Schema, &v.Name, &v.Materialized, &v.Definition)
+		if err != nil {
+			return err
+		}
+		schema.Views = append(schema.Views, v)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read views: %w", err)
+	}
+
+	err = query(ctx, q, `
+		SELECT n.nspname, t.typname, e.enumlabel
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE `+schemaFilter+`
+		ORDER BY 1, 2, e.enumsortorder`, schemas, func(rows *sql.Rows) error {
+		var enumSchema, enumName, value string
+		err := rows.Scan(&enumSchema, &enumName, &value)
+		if err != nil {
+			return err
+		}
+
+		last := len(schema.Enums) - 1
+		if last < 0 || schema.Enums[last].Schema != enumSchema || schema.Enums[last].Name != enumName {
+			schema.Enums = append(schema.Enums, Enum{Schema: enumSchema, Name: enumName})
+			last++
+		}
+		schema.Enums[last].Values = append(schema.Enums[last].Values, value)
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read enums: %w", err)
+	}
+
+	return &schema, nil
+}
+
+func query(ctx context.Context, q pkgsql.Querier, query string, schemas []string, scan func(rows *sql.Rows) error) error {
+	rows, err := q.QueryContext(ctx, query, schemas)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = scan(rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,115 @@
+package pkgintrospect_test
+
+import (
+	"context"
+	"testing"
+
+	pkgintrospect "github.com/amanbolat/pkg/postgres/introspect"
+	pkgpostgrestest "github.com/amanbolat/pkg/postgres/postgrestest"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	from := &pkgintrospect.Schema{
+		Tables: []pkgintrospect.Table{
+			{
+				Schema: "public",
+				Name:   "users",
+				Columns: []pkgintrospect.Column{
+					{Name: "id", Position: 1, Type: "bigint"},
+					{Name: "email", Position: 2, Type: "text", Nullable: true},
+				},
+			},
+			{Schema: "public", Name: "legacy"},
+		},
+		Enums: []pkgintrospect.Enum{{Schema: "public", Name: "status", Values: []string{"active"}}},
+	}
+
+	to := &pkgintrospect.Schema{
+		Tables: []pkgintrospect.Table{
+			{
+				Schema: "public",
+				Name:   "users",
+				Columns: []pkgintrospect.Column{
+					{Name: "id", Position: 1, Type: "bigint"},
+					{Name: "email", Position: 2, Type: "text", Default: pkgptr.Ptr("''::text")},
+				},
+				Indexes: []pkgintrospect.Index{
+					{Name: "users_email_idx", Definition: "CREATE INDEX users_email_idx ON public.users USING btree (email)"},
+				},
+			},
+		},
+		Enums: []pkgintrospect.Enum{{Schema: "public", Name: "status", Values: []string{"active", "blocked"}}},
+	}
+
+	changes := pkgintrospect.Diff(from, to)
+
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.String())
+	}
+
+	assert.Equal(t, []string{
+		"removed table public.legacy",
+		"changed enum public.status: active -> active, blocked",
+		"changed column public.users.email: text -> text NOT NULL DEFAULT ''::text",
+		"added index public.users.users_email_idx: CREATE INDEX users_email_idx ON public.users USING btree (email)",
+	}, got)
+	assert.Empty(t, pkgintrospect.Diff(to, to))
+}
+
+func TestInspect(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping postgres test in short mode")
+	}
+
+	srv, err := pkgpostgrestest.StartServer(context.Background(), pkgpostgrestest.ServerConfig{})
+	if err != nil {
+		t.Skipf("postgres is not available: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, srv.Close(context.Background()))
+	})
+
+	ctx := context.Background()
+	conn := srv.NewSQLConn(t)
+
+	_, err = conn.ExecContext(ctx, `
+		CREATE TYPE status AS ENUM ('active', 'blocked');
+		CREATE TABLE users (
+			id bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+			email text NOT NULL UNIQUE,
+			status status NOT NULL DEFAULT 'active',
+			balance numeric(10, 2) CHECK (balance >= 0)
+		);
+		CREATE TABLE schema_migrations (version bigint);
+		CREATE VIEW active_users AS SELECT id, email FROM users WHERE status = 'active';`)
+	require.NoError(t, err)
+
+	schema, err := pkgintrospect.Inspect(ctx, conn, pkgintrospect.Options{
+		ExcludeTables: []string{"schema_migrations"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, schema.Tables, 1)
+	users := schema.Tables[0]
+	assert.Equal(t, "public.users", users.QualifiedName())
+	require.Len(t, users.Columns, 4)
+	assert.Equal(t, "ALWAYS", users.Columns[0].Identity)
+	assert.Equal(t, "numeric(10,2)", users.Columns[3].Type)
+	assert.True(t, users.Columns[3].Nullable)
+	assert.Len(t, users.Indexes, 2)
+	assert.Len(t, users.Constraints, 3)
+
+	require.Len(t, schema.Enums, 1)
+	assert.Equal(t, []string{"active", "blocked"}, schema.Enums[0].Values)
+
+	require.Len(t, schema.Views, 1)
+	assert.Equal(t, "public.active_users", schema.Views[0].QualifiedName())
+
+	assert.Empty(t, pkgintrospect.Diff(schema, schema))
+}
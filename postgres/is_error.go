@@ -1,7 +1,10 @@
 package pkgpostgres
 
 import (
+	"context"
 	"errors"
+	"io"
+	"net"
 
 	"github.com/jackc/pgx/v5/pgconn"
 )
@@ -35,3 +38,124 @@ func IsError(err error, code string, constraintName *string) bool {
 
 	return true
 }
+
+// IsErrorCode checks if the error is a Postgres error with the given code
+// and constraint name if one was provided.
+func IsErrorCode(err error, code ErrorCode, constraintName *string) bool {
+	return IsError(err, string(code), constraintName)
+}
+
+// IsErrorCodeOneOf checks if the error is a Postgres error with one of the given codes.
+func IsErrorCodeOneOf(err error, codes ...ErrorCode) bool {
+	for _, code := range codes {
+		if IsErrorCode(err, code, nil) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsErrorClass checks if the error is a Postgres error of the given class.
+// The class is the first two characters of the error code, e.g. "23".
+func IsErrorClass(err error, class string) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	return ErrorClass(ErrorCode(pgErr.Code)) == class
+}
+
+// ErrorClass returns the class of the error code, which is the first
+// two characters of the code, e.g. "23" for "23505".
+func ErrorClass(code ErrorCode) string {
+	if len(code) < 2 {
+		return ""
+	}
+
+	return string(code[:2])
+}
+
+// IsUniqueViolation checks if the error is a unique_violation error.
+func IsUniqueViolation(err error) bool {
+	return IsErrorCode(err, Err23505, nil)
+}
+
+// IsForeignKeyViolation checks if the error is a foreign_key_violation error.
+func IsForeignKeyViolation(err error) bool {
+	return IsErrorCode(err, Err23503, nil)
+}
+
+// IsNotNullViolation checks if the error is a not_null_violation error.
+func IsNotNullViolation(err error) bool {
+	return IsErrorCode(err, Err23502, nil)
+}
+
+// IsCheckViolation checks if the error is a check_violation error.
+func IsCheckViolation(err error) bool {
+	return IsErrorCode(err, Err23514, nil)
+}
+
+// IsSerializationFailure checks if the error is a serialization_failure error.
+func IsSerializationFailure(err error) bool {
+	return IsErrorCode(err, Err40001, nil)
+}
+
+// IsDeadlockDetected checks if the error is a deadlock_detected error.
+func IsDeadlockDetected(err error) bool {
+	return IsErrorCode(err, Err40P01, nil)
+}
+
+// IsQueryCanceled checks if the error is a query_canceled error,
+// which is also returned when statement_timeout is exceeded.
+func IsQueryCanceled(err error) bool {
+	return IsErrorCode(err, Err57014, nil)
+}
+
+// IsConnectionError checks if the error is caused by a broken or
+// failed connection: connection_exception class errors, server shutdown
+// errors, unexpectedly closed connections and network errors. The errors
+// caused by the canceled or expired context are not connection errors,
+// even though context.DeadlineExceeded implements net.Error.
+func IsConnectionError(err error) bool {
+	if err == nil || isContextError(err) {
+		return false
+	}
+
+	if IsErrorClass(err, ErrorClass(Err08000)) {
+		return true
+	}
+
+	if IsErrorCodeOneOf(err, Err57P01, Err57P02, Err57P03) {
+		return true
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr)
+}
+
+// IsRetryable checks if the operation that returned the error can be
+// retried: serialization failures, deadlocks, lock timeouts, exhausted
+// connections, connection errors and errors returned before the query
+// was sent to the server.
+func IsRetryable(err error) bool {
+	if err == nil || isContextError(err) {
+		return false
+	}
+
+	if IsErrorCodeOneOf(err, Err40001, Err40P01, Err55P03, Err53300) {
+		return true
+	}
+
+	return IsConnectionError(err) || pgconn.SafeToRetry(err)
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
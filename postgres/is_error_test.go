@@ -0,0 +1,98 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorClassification(t *testing.T) {
+	t.Parallel()
+
+	uniqueErr := fmt.Errorf("insert user: %w", &pgconn.PgError{
+		Code:           "23505",
+		Message:        "duplicate key value violates unique constraint",
+		TableName:      "users",
+		ConstraintName: "users_email_key",
+	})
+
+	assert.True(t, pkgpostgres.IsErrorCode(uniqueErr, pkgpostgres.Err23505, nil))
+	assert.True(t, pkgpostgres.IsErrorCode(uniqueErr, pkgpostgres.Err23505, pkgptr.Ptr("users_email_key")))
+	assert.False(t, pkgpostgres.IsErrorCode(uniqueErr, pkgpostgres.Err23505, pkgptr.Ptr("users_pkey")))
+	assert.True(t, pkgpostgres.IsUniqueViolation(uniqueErr))
+	assert.False(t, pkgpostgres.IsForeignKeyViolation(uniqueErr))
+	assert.True(t, pkgpostgres.IsErrorClass(uniqueErr, "23"))
+	assert.False(t, pkgpostgres.IsRetryable(uniqueErr))
+	assert.Equal(t, "23", pkgpostgres.ErrorClass(pkgpostgres.Err23505))
+
+	serializationErr := &pgconn.PgError{Code: "40001"}
+	assert.True(t, pkgpostgres.IsSerializationFailure(serializationErr))
+	assert.True(t, pkgpostgres.IsRetryable(serializationErr))
+
+	connErr := &pgconn.PgError{Code: "08006"}
+	assert.True(t, pkgpostgres.IsConnectionError(connErr))
+	assert.True(t, pkgpostgres.IsRetryable(connErr))
+
+	authErr := &pgconn.PgError{Code: "28P01"}
+	assert.True(t, pkgpostgres.IsNonTransientConnectError(authErr))
+	assert.False(t, pkgpostgres.IsNonTransientConnectError(connErr))
+
+	assert.False(t, pkgpostgres.IsRetryable(errors.New("some error")))
+	assert.False(t, pkgpostgres.IsConnectionError(nil))
+
+	netErr := fmt.Errorf("query: %w", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")})
+	assert.True(t, pkgpostgres.IsConnectionError(netErr))
+	assert.True(t, pkgpostgres.IsRetryable(netErr))
+
+	// The query was canceled by the caller, the connection is fine.
+	for _, ctxErr := range []error{context.DeadlineExceeded, context.Canceled} {
+		err := fmt.Errorf("query: %w", ctxErr)
+		assert.False(t, pkgpostgres.IsConnectionError(err), ctxErr)
+		assert.False(t, pkgpostgres.IsRetryable(err), ctxErr)
+	}
+
+	pgErr, ok := pkgpostgres.AsError(uniqueErr)
+	require.True(t, ok)
+	assert.Equal(t, pkgpostgres.Err23505, pgErr.Code)
+	assert.Equal(t, "users", pgErr.TableName)
+	assert.Equal(t, "users_email_key", pgErr.ConstraintName)
+
+	var target *pgconn.PgError
+	assert.True(t, errors.As(pgErr, &target))
+
+	_, ok = pkgpostgres.AsError(errors.New("some error"))
+	assert.False(t, ok)
+}
+
+func TestErrorCodeName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "unique_violation", pkgpostgres.Err23505.Name())
+	assert.Equal(t, pkgpostgres.Err23000, pkgpostgres.Err23505.Class())
+	assert.Equal(t, "integrity_constraint_violation", pkgpostgres.Err23505.Class().Name())
+	assert.Equal(t, "unique_violation (23505)", pkgpostgres.Err23505.String())
+	assert.Equal(t, "ZZ999", pkgpostgres.ErrorCode("ZZ999").String())
+
+	code, err := pkgpostgres.ParseErrorCode("unique_violation")
+	require.NoError(t, err)
+	assert.Equal(t, pkgpostgres.Err23505, code)
+
+	code, err = pkgpostgres.ParseErrorCode("40p01")
+	require.NoError(t, err)
+	assert.Equal(t, pkgpostgres.Err40P01, code)
+
+	code, err = pkgpostgres.ParseErrorCode("string_data_right_truncation")
+	require.NoError(t, err)
+	assert.Equal(t, pkgpostgres.Err22001, code)
+
+	_, err = pkgpostgres.ParseErrorCode("unknown")
+	assert.ErrorIs(t, err, pkgpostgres.ErrInvalidErrorCode)
+}
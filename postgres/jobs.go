@@ -0,0 +1,470 @@
+package pkgpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+// JobsNotifyChannel is the channel EnqueueJob notifies with the queue name as payload.
+const JobsNotifyChannel = "pkgpostgres_jobs"
+
+const (
+	DefaultJobQueue             = "default"
+	DefaultJobMaxAttempts       = 25
+	DefaultJobConcurrency       = 10
+	DefaultJobPollInterval      = time.Second
+	DefaultJobVisibilityTimeout = time.Minute * 5
+	DefaultJobRetryDelay        = time.Second
+	DefaultJobMaxRetryDelay     = time.Hour
+)
+
+var (
+	// ErrJobAlreadyEnqueued is returned by EnqueueJob when a pending or running
+	// job with the same unique key already exists in the queue.
+	ErrJobAlreadyEnqueued = errors.New("job with the same unique key is already enqueued")
+	// ErrJobNotRetryable can be wrapped by the error returned from JobHandler
+	// to move the job to the dead letters without retrying it.
+	ErrJobNotRetryable = errors.New("job is not retryable")
+)
+
+// JobsMigrations returns the migrations that create the jobs table in the given format.
+func JobsMigrations(format pkgsql.MigrationFormat) (fs.FS, error) {
+	return embeddedMigrations("jobs", format)
+}
+
+// JobStatus is a status of the job.
+type JobStatus string
+
+const (
+	JobStatusPending JobStatus = "pending"
+	JobStatusRunning JobStatus = "running"
+	// JobStatusDead is a status of the job that exhausted its attempts.
+	JobStatusDead JobStatus = "dead"
+)
+
+// NewJob is a job to enqueue.
+type NewJob struct {
+	// Queue is the name of the queue. DefaultJobQueue is used if it is empty.
+	Queue   string
+	Kind    string
+	Payload []byte
+	// UniqueKey prevents enqueuing the job while another pending or running
+	// job with the same key exists in the queue.
+	UniqueKey string
+	// RunAt is the time to run the job at. The job runs immediately if it is zero.
+	RunAt time.Time
+	// MaxAttempts is DefaultJobMaxAttempts if it is zero.
+	MaxAttempts int
+}
+
+// Job is a job claimed by JobWorker.
+type Job struct {
+	ID        int64
+	Queue     string
+	Kind      string
+	Payload   []byte
+	UniqueKey string
+	// Attempt is the number of the current attempt starting from 1.
+	Attempt     int
+	MaxAttempts int
+	RunAt       time.Time
+	CreatedAt   time.Time
+}
+
+// EnqueueJob adds the job to the queue and returns its ID. When to is
+// a transaction, the job becomes visible to the workers after the commit.
+// Workers listening to JobsNotifyChannel are woken up.
+func EnqueueJob(ctx context.Context, to pkgsql.TableOperator, job NewJob) (int64, error) {
+	if job.Kind == "" {
+		return 0, fmt.Errorf("job kind is required")
+	}
+
+	if job.Queue == "" {
+		job.Queue = DefaultJobQueue
+	}
+
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultJobMaxAttempts
+	}
+
+	if job.Payload == nil {
+		job.Payload = []byte{}
+	}
+
+	var (
+		uniqueKey *string
+		runAt     *time.Time
+	)
+	if job.UniqueKey != "" {
+		uniqueKey = &job.UniqueKey
+	}
+	if !job.RunAt.IsZero() {
+		runAt = &job.RunAt
+	}
+
+	rows, err := to.QueryContext(ctx, `
+		INSERT INTO jobs (queue, kind, payload, unique_key, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, now()))
+		ON CONFLICT (queue, unique_key) WHERE unique_key IS NOT NULL AND status <> 'dead' DO NOTHING
+		RETURNING id`,
+		job.Queue, job.Kind, job.Payload, uniqueKey, job.MaxAttempts, runAt,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, fmt.Errorf("failed to enqueue job: %w", err)
+		}
+
+		return 0, ErrJobAlreadyEnqueued
+	}
+
+	var id int64
+	err = rows.Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan job id: %w", err)
+	}
+
+	err = rows.Close()
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+
+	if runAt == nil || !runAt.After(time.Now()) {
+		err = Notify(ctx, to, JobsNotifyChannel, job.Queue)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return id, nil
+}
+
+// RetryDeadJob moves the dead job back to the queue and resets its attempts.
+func RetryDeadJob(ctx context.Context, e pkgsql.Execer, id int64) error {
+	res, err := e.ExecContext(ctx, `
+		UPDATE jobs SET status = 'pending', attempts = 0, run_at = now(), last_error = NULL
+		WHERE id = $1 AND status = 'dead'`,
+		id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to retry job %d: %w", id, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to retry job %d: %w", id, err)
+	}
+
+	if n == 0 {
+		return fmt.Errorf("dead job %d not found", id)
+	}
+
+	return nil
+}
+
+// JobHandler handles the job. The job is retried with backoff if the handler
+// returns an error, unless the error wraps ErrJobNotRetryable. The context is
+// canceled when the visibility timeout expires.
+type JobHandler func(ctx context.Context, job Job) error
+
+type JobWorkerConfig struct {
+	// Queue is the name of the queue to process.
+	Queue *string
+	// Concurrency is the number of jobs processed at the same time.
+	Concurrency *int
+	// PollInterval is the time between two checks for new jobs when no
+	// notification is received.
+	PollInterval *time.Duration
+	// VisibilityTimeout is the time after which a running job is considered
+	// abandoned, e.g. because the worker crashed, and is run again.
+	VisibilityTimeout *time.Duration
+	// RetryDelay is the delay before the first retry. It doubles after every attempt.
+	RetryDelay *time.Duration
+	// MaxRetryDelay caps the delay between retries.
+	MaxRetryDelay *time.Duration
+	// Listen enables the wakeups on JobsNotifyChannel notifications using
+	// a Listener connected with the config. Without it, the jobs are only polled.
+	Listen *SQLConnConfig
+	// OnError is called with the errors that don't stop the worker,
+	// including the errors returned by the handlers.
+	OnError func(err error)
+}
+
+func (c *JobWorkerConfig) Validate() error {
+	if c.Queue == nil {
+		c.Queue = pkgptr.Ptr(DefaultJobQueue)
+	}
+
+	if c.Concurrency == nil {
+		c.Concurrency = pkgptr.Ptr(DefaultJobConcurrency)
+	}
+
+	if c.PollInterval == nil {
+		c.PollInterval = pkgptr.Ptr(DefaultJobPollInterval)
+	}
+
+	if c.VisibilityTimeout == nil {
+		c.VisibilityTimeout = pkgptr.Ptr(DefaultJobVisibilityTimeout)
+	}
+
+	if c.RetryDelay == nil {
+		c.RetryDelay = pkgptr.Ptr(DefaultJobRetryDelay)
+	}
+
+	if c.MaxRetryDelay == nil {
+		c.MaxRetryDelay = pkgptr.Ptr(DefaultJobMaxRetryDelay)
+	}
+
+	if *c.Queue == "" {
+		return fmt.Errorf("queue must not be empty")
+	}
+
+	if *c.Concurrency <= 0 {
+		return fmt.Errorf("concurrency must be positive")
+	}
+
+	if *c.PollInterval <= 0 || *c.VisibilityTimeout <= 0 {
+		return fmt.Errorf("poll interval and visibility timeout must be positive")
+	}
+
+	return nil
+}
+
+// JobWorker processes the jobs of a queue. Several workers can process
+// the same queue concurrently, jobs are claimed with FOR UPDATE SKIP LOCKED.
+type JobWorker struct {
+	db       pkgsql.Database
+	cfg      JobWorkerConfig
+	handlers map[string]JobHandler
+}
+
+// NewJobWorker creates a new JobWorker. Register the handlers with Handle
+// and call Run to start it.
+func NewJobWorker(db pkgsql.Database, cfg JobWorkerConfig) (*JobWorker, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JobWorker config: %w", err)
+	}
+
+	return &JobWorker{
+		db:       db,
+		cfg:      cfg,
+		handlers: make(map[string]JobHandler),
+	}, nil
+}
+
+// Handle registers the handler of the jobs of the kind. It must not be
+// called after Run. Jobs without a handler fail and are retried.
+func (w *JobWorker) Handle(kind string, handler JobHandler) *JobWorker {
+	w.handlers[kind] = handler
+
+	return w
+}
+
+// Run processes the jobs until the context is canceled.
+func (w *JobWorker) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wakeups := make([]chan struct{}, *w.cfg.Concurrency)
+	for i := range wakeups {
+		wakeups[i] = make(chan struct{}, 1)
+	}
+
+	var wg sync.WaitGroup
+
+	if w.cfg.Listen != nil {
+		listener, err := NewListener(ListenerConfig{
+			Conn:     *w.cfg.Listen,
+			Channels: []string{JobsNotifyChannel},
+			Handler: func(_ context.Context, n Notification) {
+				if n.Payload != *w.cfg.Queue {
+					return
+				}
+
+				for _, wakeup := range wakeups {
+					select {
+					case wakeup <- struct{}{}:
+					default:
+					}
+				}
+			},
+			OnDisconnect: w.onError,
+		})
+		if err != nil {
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := listener.Run(ctx)
+			if err != nil && ctx.Err() == nil {
+				w.onError(err)
+			}
+		}()
+	}
+
+	for _, wakeup := range wakeups {
+		wg.Add(1)
+		go func(wakeup <-chan struct{}) {
+			defer wg.Done()
+
+			w.loop(ctx, wakeup)
+		}(wakeup)
+	}
+
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+func (w *JobWorker) loop(ctx context.Context, wakeup <-chan struct{}) {
+	ticker := time.NewTicker(*w.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		processed, err := w.Work(ctx)
+		if err != nil && ctx.Err() == nil {
+			w.onError(err)
+		}
+
+		if processed {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *JobWorker) onError(err error) {
+	if w.cfg.OnError != nil {
+		w.cfg.OnError(err)
+	}
+}
+
+// Work claims a single job and processes it. It reports whether there was a job to process.
+func (w *JobWorker) Work(ctx context.Context) (bool, error) {
+	job, ok, err := w.claim(ctx)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	var jobErr error
+	if job.Attempt > job.MaxAttempts {
+		jobErr = fmt.Errorf("visibility timeout exceeded: %w", ErrJobNotRetryable)
+	} else {
+		jobErr = w.handle(ctx, job)
+	}
+
+	if jobErr == nil {
+		_, err = w.db.ExecContext(ctx, `DELETE FROM jobs WHERE id = $1 AND attempts = $2 AND status = 'running'`, job.ID, job.Attempt)
+		if err != nil {
+			return true, fmt.Errorf("failed to complete job %d: %w", job.ID, err)
+		}
+
+		return true, nil
+	}
+
+	err = w.fail(ctx, job, jobErr)
+	if err != nil {
+		return true, fmt.Errorf("failed to fail job %d: %w", job.ID, err)
+	}
+
+	return true, fmt.Errorf("job %d of kind %s failed: %w", job.ID, job.Kind, jobErr)
+}
+
+func (w *JobWorker) claim(ctx context.Context) (Job, bool, error) {
+	rows, err := w.db.QueryContext(ctx, `
+		UPDATE jobs
+		SET status = 'running', attempts = attempts + 1, locked_until = now() + $2 * interval '1 millisecond'
+		WHERE id = (
+			SELECT id
+			FROM jobs
+			WHERE queue = $1
+			  AND (status = 'pending' AND run_at <= now() OR status = 'running' AND locked_until <= now())
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, queue, kind, payload, COALESCE(unique_key, ''), attempts, max_attempts, run_at, created_at`,
+		*w.cfg.Queue, w.cfg.VisibilityTimeout.Milliseconds(),
+	)
+	if err != nil {
+		return Job{}, false, fmt.Errorf("failed to claim job: %w", err)
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return Job{}, false, fmt.Errorf("failed to claim job: %w", err)
+		}
+
+		return Job{}, false, nil
+	}
+
+	var job Job
+	err = rows.Scan(&job.ID, &job.Queue, &job.Kind, &job.Payload, &job.UniqueKey,
+		&job.Attempt, &job.MaxAttempts, &job.RunAt, &job.CreatedAt)
+	if err != nil {
+		return Job{}, false, fmt.Errorf("failed to scan job: %w", err)
+	}
+
+	return job, true, nil
+}
+
+func (w *JobWorker) handle(ctx context.Context, job Job) (err error) {
+	handler, ok := w.handlers[job.Kind]
+	if !ok {
+		return fmt.Errorf("no handler for job kind %s", job.Kind)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *w.cfg.VisibilityTimeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job handler panicked: %v", r)
+		}
+	}()
+
+	return handler(ctx, job)
+}
+
+func (w *JobWorker) fail(ctx context.Context, job Job, jobErr error) error {
+	if job.Attempt >= job.MaxAttempts || errors.Is(jobErr, ErrJobNotRetryable) {
+		_, err := w.db.ExecContext(ctx, `
+			UPDATE jobs SET status = 'dead', locked_until = NULL, last_error = $3
+			WHERE id = $1 AND attempts = $2 AND status = 'running'`,
+			job.ID, job.Attempt, jobErr.Error(),
+		)
+
+		return err
+	}
+
+	_, err := w.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = 'pending', locked_until = NULL, last_error = $3, run_at = now() + $4 * interval '1 millisecond'
+		WHERE id = $1 AND attempts = $2 AND status = 'running'`,
+		job.ID, job.Attempt, jobErr.Error(), backoffDelay(job.Attempt, *w.cfg.RetryDelay, *w.cfg.MaxRetryDelay).Milliseconds(),
+	)
+
+	return err
+}
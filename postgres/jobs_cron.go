@@ -0,0 +1,162 @@
+package pkgpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/robfig/cron/v3"
+)
+
+const DefaultCronCheckInterval = time.Second * 30
+
+// CronJob is a job enqueued periodically by JobScheduler.
+type CronJob struct {
+	// Name identifies the cron job. It must be unique among the cron jobs.
+	Name string
+	// Schedule is a standard cron expression with 5 fields, e.g. "*/5 * * * *",
+	// or a descriptor like "@hourly". It is evaluated in UTC, regardless of
+	// the time zone of the database and the scheduler. Prefix it with
+	// CRON_TZ to use another time zone, e.g. "CRON_TZ=Europe/Berlin 0 6 * * *".
+	Schedule string
+	// Job is enqueued on every run. Its UniqueKey and RunAt are overridden.
+	Job NewJob
+}
+
+type cronEntry struct {
+	CronJob
+	schedule cron.Schedule
+}
+
+// JobSchedulerConfig is a configuration of JobScheduler.
+type JobSchedulerConfig struct {
+	// CheckInterval is the time between two checks of the cron jobs.
+	CheckInterval *time.Duration
+	// OnError is called with the errors that don't stop the scheduler.
+	OnError func(err error)
+}
+
+func (c *JobSchedulerConfig) Validate() error {
+	if c.CheckInterval == nil {
+		c.CheckInterval = pkgptr.Ptr(DefaultCronCheckInterval)
+	}
+
+	if *c.CheckInterval <= 0 {
+		return fmt.Errorf("check interval must be positive")
+	}
+
+	return nil
+}
+
+// JobScheduler enqueues the cron jobs. Several schedulers can run
+// concurrently, every run of a cron job is enqueued only once using
+// the unique key made of its name and time.
+type JobScheduler struct {
+	db      pkgsql.Database
+	cfg     JobSchedulerConfig
+	entries []cronEntry
+}
+
+// NewJobScheduler creates a new JobScheduler. Call Run to start it.
+func NewJobScheduler(db pkgsql.Database, cfg JobSchedulerConfig, jobs ...CronJob) (*JobScheduler, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JobScheduler config: %w", err)
+	}
+
+	s := &JobScheduler{
+		db:  db,
+		cfg: cfg,
+	}
+
+	names := make(map[string]struct{}, len(jobs))
+	for _, job := range jobs {
+		if _, ok := names[job.Name]; ok || job.Name == "" {
+			return nil, fmt.Errorf("cron job name %q is empty or not unique", job.Name)
+		}
+		names[job.Name] = struct{}{}
+
+		schedule, err := cron.ParseStandard(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule of cron job %s: %w", job.Name, err)
+		}
+
+		s.entries = append(s.entries, cronEntry{
+			CronJob:  job,
+			schedule: schedule,
+		})
+	}
+
+	return s, nil
+}
+
+// Run enqueues the next run of every cron job until the context is canceled.
+// The errors are reported to OnError and the cron jobs are scheduled again
+// on the next check.
+func (s *JobScheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(*s.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		err := s.Schedule(ctx)
+		if err != nil && ctx.Err() == nil && s.cfg.OnError != nil {
+			s.cfg.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Schedule enqueues the next run of every cron job if it is not enqueued yet.
+// The database time is used, so the clocks of the schedulers don't need to be in sync.
+func (s *JobScheduler) Schedule(ctx context.Context) error {
+	now, err := s.now(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range s.entries {
+		next := entry.schedule.Next(now)
+
+		job := entry.Job
+		job.RunAt = next
+		job.UniqueKey = "cron:" + entry.Name + ":" + strconv.FormatInt(next.Unix(), 10)
+
+		_, err = EnqueueJob(ctx, s.db, job)
+		if err != nil && !errors.Is(err, ErrJobAlreadyEnqueued) {
+			return fmt.Errorf("failed to schedule cron job %s: %w", entry.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *JobScheduler) now(ctx context.Context) (time.Time, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT now()")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get database time: %w", err)
+	}
+	defer rows.Close()
+
+	var now time.Time
+	if rows.Next() {
+		err = rows.Scan(&now)
+	} else {
+		err = rows.Err()
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get database time: %w", err)
+	}
+
+	// The time zone of the scanned time depends on the driver and the
+	// session, so it is normalized to keep the schedules in UTC.
+	return now.UTC(), nil
+}
@@ -0,0 +1,89 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobsMigrations(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []pkgsql.MigrationFormat{pkgsql.MigrationFormatFlyway, pkgsql.MigrationFormatGomigrate} {
+		fsys, err := pkgpostgres.JobsMigrations(format)
+		require.NoError(t, err)
+
+		issues, err := pkgsql.LintMigrations(pkgsql.MigratorConfig{
+			MigrationsFs:  fsys,
+			MigrationsDir: ".",
+			Format:        format,
+		}, pkgsql.LintOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, issues, format.String())
+	}
+}
+
+func TestNewJobScheduler(t *testing.T) {
+	t.Parallel()
+
+	_, err := pkgpostgres.NewJobScheduler(nil, pkgpostgres.JobSchedulerConfig{},
+		pkgpostgres.CronJob{Name: "report", Schedule: "0 * * * *"},
+		pkgpostgres.CronJob{Name: "cleanup", Schedule: "@daily"},
+		pkgpostgres.CronJob{Name: "digest", Schedule: "CRON_TZ=Europe/Berlin 0 6 * * *"},
+	)
+	require.NoError(t, err)
+
+	_, err = pkgpostgres.NewJobScheduler(nil, pkgpostgres.JobSchedulerConfig{}, pkgpostgres.CronJob{Name: "report", Schedule: "every hour"})
+	assert.ErrorContains(t, err, "invalid schedule of cron job report")
+
+	_, err = pkgpostgres.NewJobScheduler(nil, pkgpostgres.JobSchedulerConfig{},
+		pkgpostgres.CronJob{Name: "report", Schedule: "@hourly"},
+		pkgpostgres.CronJob{Name: "report", Schedule: "@daily"},
+	)
+	assert.ErrorContains(t, err, "not unique")
+
+	_, err = pkgpostgres.NewJobScheduler(nil, pkgpostgres.JobSchedulerConfig{CheckInterval: pkgptr.Ptr(time.Duration(0))})
+	assert.Error(t, err)
+}
+
+func TestJobScheduler_Run(t *testing.T) {
+	t.Parallel()
+
+	errDB := errors.New("connection refused")
+	db := newStubDB(t, &stubConn{
+		query: func(string, []any) ([]string, [][]driver.Value, error) {
+			return nil, nil, errDB
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The scheduler keeps running after the failed checks.
+	var errs []error
+	s, err := pkgpostgres.NewJobScheduler(db, pkgpostgres.JobSchedulerConfig{
+		CheckInterval: pkgptr.Ptr(time.Millisecond),
+		OnError: func(err error) {
+			errs = append(errs, err)
+			if len(errs) == 3 {
+				cancel()
+			}
+		},
+	}, pkgpostgres.CronJob{Name: "report", Schedule: "@hourly"})
+	require.NoError(t, err)
+
+	err = s.Run(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Len(t, errs, 3)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, errDB)
+	}
+}
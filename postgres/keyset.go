@@ -0,0 +1,436 @@
+package pkgpostgres
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 1000
+)
+
+// ErrInvalidCursor is returned when the cursor is malformed, was tampered
+// with or was created for another sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortDirection is the direction of the sort column.
+type SortDirection string
+
+const (
+	SortAsc  SortDirection = "ASC"
+	SortDesc SortDirection = "DESC"
+)
+
+// SortColumn is a column the rows are sorted by.
+type SortColumn struct {
+	// Name is the possibly qualified column name. QueryPage sorts the
+	// output of the query, so it accepts only unqualified names.
+	Name      string
+	Direction SortDirection
+}
+
+type KeysetConfig struct {
+	// Columns are the sort columns. They must identify the row uniquely,
+	// so the last one is usually the primary key. The columns must not
+	// be NULL.
+	Columns []SortColumn
+	// Secret is the key the cursors are signed with, so the cursors
+	// tampered with by the clients are rejected.
+	Secret []byte
+}
+
+func (c *KeysetConfig) Validate() error {
+	if len(c.Columns) == 0 {
+		return fmt.Errorf("at least one sort column is required")
+	}
+
+	for i, col := range c.Columns {
+		if col.Name == "" {
+			return fmt.Errorf("sort column %d has no name", i)
+		}
+
+		switch col.Direction {
+		case SortAsc, SortDesc:
+		default:
+			return fmt.Errorf("unknown sort direction %s of column %s", col.Direction, col.Name)
+		}
+	}
+
+	if len(c.Secret) == 0 {
+		return fmt.Errorf("secret is required")
+	}
+
+	return nil
+}
+
+// Keyset builds the queries for keyset pagination and encodes the cursors
+// pointing to the last row of the page. Unlike OFFSET, keyset pagination
+// doesn't scan the skipped rows and isn't affected by concurrent inserts.
+//
+// Example:
+//
+//	keyset, _ := pkgpostgres.NewKeyset(pkgpostgres.KeysetConfig{
+//	    Columns: []pkgpostgres.SortColumn{
+//	        {Name: "created_at", Direction: pkgpostgres.SortDesc},
+//	        {Name: "id", Direction: pkgpostgres.SortDesc},
+//	    },
+//	    Secret: secret,
+//	})
+//
+//	where, args, err := keyset.Where(req.Cursor, 1)
+//	query := "SELECT id, created_at FROM orders WHERE user_id = $1 AND " + where +
+//	    " ORDER BY " + keyset.OrderBy() + " LIMIT 50"
+//
+//	// after the rows are scanned
+//	next, err := keyset.Cursor(last.CreatedAt, last.ID)
+type Keyset struct {
+	columns []SortColumn
+	secret  []byte
+	// signature binds the cursors to the sort columns.
+	signature string
+}
+
+// NewKeyset creates a new Keyset.
+func NewKeyset(cfg KeysetConfig) (*Keyset, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Keyset config: %w", err)
+	}
+
+	parts := make([]string, 0, len(cfg.Columns))
+	for _, col := range cfg.Columns {
+		parts = append(parts, col.Name+" "+string(col.Direction))
+	}
+
+	return &Keyset{
+		columns:   cfg.Columns,
+		secret:    cfg.Secret,
+		signature: strings.Join(parts, ","),
+	}, nil
+}
+
+// OrderBy returns the ORDER BY list of the sort columns.
+func (k *Keyset) OrderBy() string {
+	parts := make([]string, 0, len(k.columns))
+	for _, col := range k.columns {
+		parts = append(parts, parseIdentifier(col.Name).Sanitize()+" "+string(col.Direction))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Where returns the predicate that selects the rows after the cursor and
+// its arguments. The placeholders are numbered after argOffset, which is
+// the number of the other arguments of the query. The predicate is TRUE
+// if the cursor is empty, i.e. for the first page.
+func (k *Keyset) Where(cursor string, argOffset int) (string, []any, error) {
+	if cursor == "" {
+		return "TRUE", nil, nil
+	}
+
+	values, err := k.decode(cursor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	placeholders := make([]string, len(k.columns))
+	names := make([]string, len(k.columns))
+	for i, col := range k.columns {
+		placeholders[i] = fmt.Sprintf("$%d", argOffset+i+1)
+		names[i] = parseIdentifier(col.Name).Sanitize()
+	}
+
+	// Row comparison can use a composite index, but it only works when
+	// all the columns are sorted in the same direction.
+	if k.uniformDirection() {
+		op := ">"
+		if k.columns[0].Direction == SortDesc {
+			op = "<"
+		}
+
+		if len(names) == 1 {
+			return fmt.Sprintf("%s %s %s", names[0], op, placeholders[0]), values, nil
+		}
+
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(names, ", "), op, strings.Join(placeholders, ", ")), values, nil
+	}
+
+	// (a > $1) OR (a = $1 AND b < $2) OR ...
+	disjuncts := make([]string, 0, len(k.columns))
+	for i, col := range k.columns {
+		conjuncts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conjuncts = append(conjuncts, fmt.Sprintf("%s = %s", names[j], placeholders[j]))
+		}
+
+		op := ">"
+		if col.Direction == SortDesc {
+			op = "<"
+		}
+		conjuncts = append(conjuncts, fmt.Sprintf("%s %s %s", names[i], op, placeholders[i]))
+
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(disjuncts, " OR ") + ")", values, nil
+}
+
+func (k *Keyset) uniformDirection() bool {
+	for _, col := range k.columns[1:] {
+		if col.Direction != k.columns[0].Direction {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cursorValue is a typed value of the sort column in the cursor.
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+const (
+	cursorTypeString  = "s"
+	cursorTypeInt     = "i"
+	cursorTypeFloat   = "f"
+	cursorTypeBool    = "b"
+	cursorTypeTime    = "t"
+	cursorTypeDecimal = "d"
+)
+
+// Cursor returns the opaque cursor pointing to the row with the values
+// of the sort columns. The values must be in the order of the columns.
+// Strings, integers, floats, bools, time.Time and pkgdecimal.Decimal
+// are supported.
+func (k *Keyset) Cursor(values ...any) (string, error) {
+	if len(values) != len(k.columns) {
+		return "", fmt.Errorf("got %d cursor values for %d sort columns", len(values), len(k.columns))
+	}
+
+	encoded := make([]cursorValue, 0, len(values))
+	for i, v := range values {
+		cv, err := encodeCursorValue(v)
+		if err != nil {
+			return "", fmt.Errorf("invalid value of sort column %s: %w", k.columns[i].Name, err)
+		}
+		encoded = append(encoded, cv)
+	}
+
+	payload, err := json.Marshal(encoded)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(append(payload, k.sign(payload)...)), nil
+}
+
+func (k *Keyset) decode(cursor string) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(b) < sha256.Size {
+		return nil, ErrInvalidCursor
+	}
+
+	payload, mac := b[:len(b)-sha256.Size], b[len(b)-sha256.Size:]
+	if !hmac.Equal(mac, k.sign(payload)) {
+		return nil, ErrInvalidCursor
+	}
+
+	var encoded []cursorValue
+	err = json.Unmarshal(payload, &encoded)
+	if err != nil || len(encoded) != len(k.columns) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]any, 0, len(encoded))
+	for _, cv := range encoded {
+		v, err := decodeCursorValue(cv)
+		if err != nil {
+			return nil, errors.Join(ErrInvalidCursor, err)
+		}
+		values = append(values, v)
+	}
+
+	return values, nil
+}
+
+func (k *Keyset) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, k.secret)
+	_, _ = mac.Write([]byte(k.signature))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write(payload)
+
+	return mac.Sum(nil)
+}
+
+func encodeCursorValue(v any) (cursorValue, error) {
+	switch v := v.(type) {
+	case string:
+		return cursorValue{Type: cursorTypeString, Value: v}, nil
+	case int:
+		return cursorValue{Type: cursorTypeInt, Value: fmt.Sprint(v)}, nil
+	case int16:
+		return cursorValue{Type: cursorTypeInt, Value: fmt.Sprint(v)}, nil
+	case int32:
+		return cursorValue{Type: cursorTypeInt, Value: fmt.Sprint(v)}, nil
+	case int64:
+		return cursorValue{Type: cursorTypeInt, Value: fmt.Sprint(v)}, nil
+	case float64:
+		return cursorValue{Type: cursorTypeFloat, Value: fmt.Sprint(v)}, nil
+	case bool:
+		return cursorValue{Type: cursorTypeBool, Value: fmt.Sprint(v)}, nil
+	case time.Time:
+		return cursorValue{Type: cursorTypeTime, Value: v.Format(time.RFC3339Nano)}, nil
+	case pkgdecimal.Decimal:
+		return cursorValue{Type: cursorTypeDecimal, Value: v.String()}, nil
+	case *pkgdecimal.Decimal:
+		if v != nil {
+			return cursorValue{Type: cursorTypeDecimal, Value: v.String()}, nil
+		}
+	case nil:
+	default:
+		return cursorValue{}, fmt.Errorf("unsupported type %T", v)
+	}
+
+	return cursorValue{}, fmt.Errorf("value must not be nil")
+}
+
+func decodeCursorValue(cv cursorValue) (any, error) {
+	var (
+		v   any
+		err error
+	)
+
+	switch cv.Type {
+	case cursorTypeString:
+		v = cv.Value
+	case cursorTypeInt:
+		var i int64
+		_, err = fmt.Sscan(cv.Value, &i)
+		v = i
+	case cursorTypeFloat:
+		var f float64
+		_, err = fmt.Sscan(cv.Value, &f)
+		v = f
+	case cursorTypeBool:
+		var b bool
+		_, err = fmt.Sscan(cv.Value, &b)
+		v = b
+	case cursorTypeTime:
+		v, err = time.Parse(time.RFC3339Nano, cv.Value)
+	case cursorTypeDecimal:
+		v, err = pkgdecimal.FromStr(cv.Value)
+	default:
+		err = fmt.Errorf("unknown cursor value type %s", cv.Type)
+	}
+
+	return v, err
+}
+
+// PageRequest is a request for the page that starts after the cursor.
+type PageRequest struct {
+	// Cursor is empty for the first page.
+	Cursor string
+	// Limit is the maximum number of items on the page. DefaultPageLimit
+	// is used if it is not positive. It is capped by MaxPageLimit.
+	Limit int
+}
+
+// Page is a page of items.
+type Page[T any] struct {
+	Items []T
+	// NextCursor points to the last item. It is empty on the last page.
+	NextCursor string
+}
+
+// QueryPage runs the query as a subquery filtered by the cursor, sorted by
+// the sort columns and limited by the page size. The sort columns must be
+// the output columns of the query, so the qualified names are rejected.
+// scan scans the row and key returns the values of the sort columns of
+// the item.
+//
+// Example:
+//
+//	page, err := pkgpostgres.QueryPage(ctx, db, keyset, req,
+//	    "SELECT id, created_at, total FROM orders WHERE user_id = $1", []any{userID},
+//	    func(rows *sql.Rows) (Order, error) {
+//	        var o Order
+//	        err := rows.Scan(&o.ID, &o.CreatedAt, &o.Total)
+//	        return o, err
+//	    },
+//	    func(o Order) []any { return []any{o.CreatedAt, o.ID} },
+//	)
+func QueryPage[T any](
+	ctx context.Context,
+	q pkgsql.Querier,
+	keyset *Keyset,
+	req PageRequest,
+	query string,
+	args []any,
+	scan func(rows *sql.Rows) (T, error),
+	key func(item T) []any,
+) (Page[T], error) {
+	for _, col := range keyset.columns {
+		if len(parseIdentifier(col.Name)) > 1 {
+			return Page[T]{}, fmt.Errorf("sort column %s must be an unqualified output column of the query", col.Name)
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	limit = min(limit, MaxPageLimit)
+
+	where, cursorArgs, err := keyset.Where(req.Cursor, len(args))
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	// One more row is fetched to find out whether there is the next page.
+	pageQuery := fmt.Sprintf("SELECT * FROM (%s) AS page WHERE %s ORDER BY %s LIMIT %d",
+		query, where, keyset.OrderBy(), limit+1)
+
+	rows, err := q.QueryContext(ctx, pageQuery, append(args[:len(args):len(args)], cursorArgs...)...)
+	if err != nil {
+		return Page[T]{}, fmt.Errorf("failed to query page: %w", err)
+	}
+	defer rows.Close()
+
+	var page Page[T]
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return Page[T]{}, fmt.Errorf("failed to scan page item: %w", err)
+		}
+		page.Items = append(page.Items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return Page[T]{}, fmt.Errorf("failed to query page: %w", err)
+	}
+
+	if len(page.Items) > limit {
+		page.Items = page.Items[:limit]
+
+		page.NextCursor, err = keyset.Cursor(key(page.Items[limit-1])...)
+		if err != nil {
+			return Page[T]{}, err
+		}
+	}
+
+	return page, nil
+}
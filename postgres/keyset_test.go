@@ -0,0 +1,160 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyset(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	keyset, err := pkgpostgres.NewKeyset(pkgpostgres.KeysetConfig{
+		Columns: []pkgpostgres.SortColumn{
+			{Name: "created_at", Direction: pkgpostgres.SortDesc},
+			{Name: "id", Direction: pkgpostgres.SortDesc},
+		},
+		Secret: secret,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `"created_at" DESC, "id" DESC`, keyset.OrderBy())
+
+	where, args, err := keyset.Where("", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "TRUE", where)
+	assert.Empty(t, args)
+
+	createdAt := time.Date(2023, 11, 20, 10, 0, 0, 123456789, time.FixedZone("", 3600))
+	cursor, err := keyset.Cursor(createdAt, 42)
+	require.NoError(t, err)
+
+	where, args, err = keyset.Where(cursor, 1)
+	require.NoError(t, err)
+	assert.Equal(t, `("created_at", "id") < ($2, $3)`, where)
+	require.Len(t, args, 2)
+	assert.True(t, createdAt.Equal(args[0].(time.Time)))
+	assert.Equal(t, int64(42), args[1])
+
+	tampered := []byte(cursor)
+	tampered[2] ^= 1
+	_, _, err = keyset.Where(string(tampered), 0)
+	assert.ErrorIs(t, err, pkgpostgres.ErrInvalidCursor)
+
+	other, err := pkgpostgres.NewKeyset(pkgpostgres.KeysetConfig{
+		Columns: []pkgpostgres.SortColumn{
+			{Name: "created_at", Direction: pkgpostgres.SortAsc},
+			{Name: "id", Direction: pkgpostgres.SortAsc},
+		},
+		Secret: secret,
+	})
+	require.NoError(t, err)
+	_, _, err = other.Where(cursor, 0)
+	assert.ErrorIs(t, err, pkgpostgres.ErrInvalidCursor)
+}
+
+func TestKeyset_MixedDirections(t *testing.T) {
+	t.Parallel()
+
+	keyset, err := pkgpostgres.NewKeyset(pkgpostgres.KeysetConfig{
+		Columns: []pkgpostgres.SortColumn{
+			{Name: "price", Direction: pkgpostgres.SortDesc},
+			{Name: "id", Direction: pkgpostgres.SortAsc},
+		},
+		Secret: []byte("secret"),
+	})
+	require.NoError(t, err)
+
+	cursor, err := keyset.Cursor(pkgdecimal.MustFromStr("12.50"), "b7f0")
+	require.NoError(t, err)
+
+	where, args, err := keyset.Where(cursor, 0)
+	require.NoError(t, err)
+	assert.Equal(t, `(("price" < $1) OR ("price" = $1 AND "id" > $2))`, where)
+	require.Len(t, args, 2)
+	assert.Equal(t, "12.50", args[0].(pkgdecimal.Decimal).String())
+	assert.Equal(t, "b7f0", args[1])
+
+	_, err = keyset.Cursor(nil, "b7f0")
+	assert.Error(t, err)
+}
+
+func TestQueryPage(t *testing.T) {
+	t.Parallel()
+
+	keyset, err := pkgpostgres.NewKeyset(pkgpostgres.KeysetConfig{
+		Columns: []pkgpostgres.SortColumn{{Name: "id", Direction: pkgpostgres.SortAsc}},
+		Secret:  []byte("secret"),
+	})
+	require.NoError(t, err)
+
+	var rows [][]driver.Value
+	conn := &stubConn{query: func(string, []any) ([]string, [][]driver.Value, error) {
+		return []string{"id"}, rows, nil
+	}}
+	db := newStubDB(t, conn)
+
+	queryPage := func(req pkgpostgres.PageRequest) (pkgpostgres.Page[int64], error) {
+		return pkgpostgres.QueryPage(context.Background(), db, keyset, req,
+			"SELECT id FROM orders WHERE user_id = $1", []any{"u1"},
+			func(rows *sql.Rows) (int64, error) {
+				var id int64
+				err := rows.Scan(&id)
+
+				return id, err
+			},
+			func(id int64) []any { return []any{id} },
+		)
+	}
+
+	// One more row than the limit is fetched, so there is the next page.
+	rows = [][]driver.Value{{int64(1)}, {int64(2)}, {int64(3)}}
+	page, err := queryPage(pkgpostgres.PageRequest{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, page.Items)
+
+	cursor, err := keyset.Cursor(int64(2))
+	require.NoError(t, err)
+	assert.Equal(t, cursor, page.NextCursor)
+
+	rows = [][]driver.Value{{int64(3)}}
+	page, err = queryPage(pkgpostgres.PageRequest{Cursor: page.NextCursor, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3}, page.Items)
+	assert.Empty(t, page.NextCursor)
+
+	assert.Equal(t, []stubStatement{
+		{
+			query: `SELECT * FROM (SELECT id FROM orders WHERE user_id = $1) AS page WHERE TRUE ORDER BY "id" ASC LIMIT 3`,
+			args:  []any{"u1"},
+		},
+		{
+			query: `SELECT * FROM (SELECT id FROM orders WHERE user_id = $1) AS page WHERE "id" > $2 ORDER BY "id" ASC LIMIT 3`,
+			args:  []any{"u1", int64(2)},
+		},
+	}, conn.Statements())
+
+	_, err = queryPage(pkgpostgres.PageRequest{Cursor: "invalid"})
+	assert.ErrorIs(t, err, pkgpostgres.ErrInvalidCursor)
+
+	qualified, err := pkgpostgres.NewKeyset(pkgpostgres.KeysetConfig{
+		Columns: []pkgpostgres.SortColumn{{Name: "o.id", Direction: pkgpostgres.SortAsc}},
+		Secret:  []byte("secret"),
+	})
+	require.NoError(t, err)
+
+	_, err = pkgpostgres.QueryPage(context.Background(), db, qualified, pkgpostgres.PageRequest{},
+		"SELECT o.id FROM orders o", nil,
+		func(rows *sql.Rows) (int64, error) { return 0, nil },
+		func(id int64) []any { return []any{id} },
+	)
+	assert.ErrorContains(t, err, "must be an unqualified output column")
+	assert.Len(t, conn.Statements(), 2)
+}
@@ -0,0 +1,178 @@
+package pkgpostgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+)
+
+const (
+	DefaultLeaderRetryInterval = time.Second * 5
+	DefaultLeaderCheckInterval = time.Second * 5
+	DefaultLeaderUnlockTimeout = time.Second * 5
+)
+
+type LeaderElectorConfig struct {
+	// Lock is the advisory lock held by the leader.
+	Lock AdvisoryLock
+	// RetryInterval is the time between two attempts to become the leader.
+	RetryInterval *time.Duration
+	// CheckInterval is the time between two checks of the leader's connection.
+	CheckInterval *time.Duration
+	// OnBecameLeader is called in a separate goroutine when the elector becomes
+	// the leader. The context is canceled when the leadership is lost or the
+	// elector is stopped. The elector doesn't try to become the leader again
+	// and Run doesn't return until the function returns.
+	OnBecameLeader func(ctx context.Context)
+	// OnLostLeadership is called when the leadership is lost. err is nil
+	// when the elector is stopped.
+	OnLostLeadership func(err error)
+	// OnError is called with the errors of the attempts to become the leader.
+	OnError func(err error)
+}
+
+func (c *LeaderElectorConfig) Validate() error {
+	if c.RetryInterval == nil {
+		c.RetryInterval = pkgptr.Ptr(DefaultLeaderRetryInterval)
+	}
+
+	if c.CheckInterval == nil {
+		c.CheckInterval = pkgptr.Ptr(DefaultLeaderCheckInterval)
+	}
+
+	if *c.RetryInterval <= 0 || *c.CheckInterval <= 0 {
+		return fmt.Errorf("retry and check intervals must be positive")
+	}
+
+	return nil
+}
+
+// LeaderElector elects a single leader among the replicas using a session
+// scoped advisory lock held on a dedicated connection. The leadership is
+// lost when the connection is broken, so the other replicas can take it over.
+type LeaderElector struct {
+	db       *sql.DB
+	cfg      LeaderElectorConfig
+	isLeader atomic.Bool
+}
+
+// NewLeaderElector creates a new LeaderElector that takes a dedicated
+// connection from db, e.g. SQLConn.DB. Call Run to start it.
+func NewLeaderElector(db *sql.DB, cfg LeaderElectorConfig) (*LeaderElector, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid LeaderElector config: %w", err)
+	}
+
+	return &LeaderElector{
+		db:  db,
+		cfg: cfg,
+	}, nil
+}
+
+// IsLeader reports whether the elector is the leader.
+func (e *LeaderElector) IsLeader() bool {
+	return e.isLeader.Load()
+}
+
+// Run tries to become the leader and holds the leadership until the
+// context is canceled. The lock is released before Run returns.
+func (e *LeaderElector) Run(ctx context.Context) error {
+	ticker := time.NewTicker(*e.cfg.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		err := e.campaign(ctx)
+		if err != nil && ctx.Err() == nil && e.cfg.OnError != nil {
+			e.cfg.OnError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// campaign tries to acquire the lock and, if it succeeds, holds it
+// until the connection is broken or the context is canceled.
+func (e *LeaderElector) campaign(ctx context.Context) error {
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	ok, err := e.cfg.Lock.TryLock(ctx, conn)
+	if err != nil || !ok {
+		return err
+	}
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	e.isLeader.Store(true)
+
+	var wg sync.WaitGroup
+	if e.cfg.OnBecameLeader != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			e.cfg.OnBecameLeader(leaderCtx)
+		}()
+	}
+
+	lostErr := e.hold(ctx, conn)
+
+	cancel()
+	wg.Wait()
+	e.isLeader.Store(false)
+
+	unlockErr := lostErr
+	if unlockErr == nil {
+		unlockCtx, unlockCancel := context.WithTimeout(context.Background(), DefaultLeaderUnlockTimeout)
+		defer unlockCancel()
+
+		unlockErr = e.cfg.Lock.Unlock(unlockCtx, conn)
+	}
+
+	if unlockErr != nil {
+		// The connection is closed instead of returning it to the pool,
+		// so the server releases the lock.
+		_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+
+	if e.cfg.OnLostLeadership != nil {
+		e.cfg.OnLostLeadership(lostErr)
+	}
+
+	return lostErr
+}
+
+// hold checks the connection until it is broken or the context is canceled.
+// It returns nil when the context is canceled.
+func (e *LeaderElector) hold(ctx context.Context, conn *sql.Conn) error {
+	ticker := time.NewTicker(*e.cfg.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		err := conn.PingContext(ctx)
+		if err != nil && ctx.Err() == nil {
+			return fmt.Errorf("lost leader connection: %w", err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLeaderElector(t *testing.T) {
+	t.Parallel()
+
+	_, err := pkgpostgres.NewLeaderElector(nil, pkgpostgres.LeaderElectorConfig{})
+	require.NoError(t, err)
+
+	_, err = pkgpostgres.NewLeaderElector(nil, pkgpostgres.LeaderElectorConfig{RetryInterval: pkgptr.Ptr(time.Duration(0))})
+	assert.Error(t, err)
+}
+
+func TestLeaderElector_Failover(t *testing.T) {
+	srv := startServer(t)
+	dsn := srv.NewDatabase(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	lock := pkgpostgres.NewAdvisoryLockFromString("leader_elector_test")
+
+	type elector struct {
+		*pkgpostgres.LeaderElector
+		became chan struct{}
+		lost   chan error
+		cancel context.CancelFunc
+		runErr chan error
+	}
+
+	start := func(name string) *elector {
+		t.Helper()
+
+		conn, err := pkgpostgres.NewSQLConn(ctx, pkgpostgres.SQLConnConfig{
+			DSN:     dsn,
+			Session: pkgpostgres.SessionSettings{ApplicationName: pkgptr.Ptr(name)},
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		e := &elector{
+			became: make(chan struct{}, 1),
+			lost:   make(chan error),
+			runErr: make(chan error, 1),
+		}
+		e.LeaderElector, err = pkgpostgres.NewLeaderElector(conn.DB, pkgpostgres.LeaderElectorConfig{
+			Lock:          lock,
+			RetryInterval: pkgptr.Ptr(time.Millisecond * 100),
+			CheckInterval: pkgptr.Ptr(time.Millisecond * 100),
+			OnBecameLeader: func(context.Context) {
+				e.became <- struct{}{}
+			},
+			// The elector doesn't campaign again until the test receives
+			// the error, so the other elector can take over the leadership.
+			OnLostLeadership: func(err error) {
+				select {
+				case e.lost <- err:
+				case <-ctx.Done():
+				}
+			},
+		})
+		require.NoError(t, err)
+
+		var runCtx context.Context
+		runCtx, e.cancel = context.WithCancel(ctx)
+		go func() {
+			e.runErr <- e.Run(runCtx)
+		}()
+
+		return e
+	}
+
+	waitBecame := func(e *elector) {
+		t.Helper()
+
+		select {
+		case <-e.became:
+		case <-ctx.Done():
+			t.Fatal("elector didn't become the leader")
+		}
+	}
+
+	waitLost := func(e *elector) error {
+		t.Helper()
+
+		select {
+		case err := <-e.lost:
+			return err
+		case <-ctx.Done():
+			t.Fatal("elector didn't lose the leadership")
+
+			return nil
+		}
+	}
+
+	first := start("leader_elector_first")
+	waitBecame(first)
+	assert.True(t, first.IsLeader())
+
+	second := start("leader_elector_second")
+	time.Sleep(time.Millisecond * 500)
+	assert.False(t, second.IsLeader())
+
+	// The leadership is lost when the leader's connection is broken,
+	// and the other elector takes it over.
+	conn, err := pkgpostgres.NewSQLConn(ctx, pkgpostgres.SQLConnConfig{DSN: dsn})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE application_name = 'leader_elector_first'`)
+	require.NoError(t, err)
+
+	waitBecame(second)
+	assert.True(t, second.IsLeader())
+
+	assert.Error(t, waitLost(first))
+	assert.False(t, first.IsLeader())
+
+	// The lock is released when the leader is stopped, so the first
+	// elector acquires it again.
+	second.cancel()
+	assert.NoError(t, waitLost(second))
+	assert.ErrorIs(t, <-second.runErr, context.Canceled)
+	assert.False(t, second.IsLeader())
+
+	waitBecame(first)
+	assert.True(t, first.IsLeader())
+
+	first.cancel()
+	assert.NoError(t, waitLost(first))
+	assert.ErrorIs(t, <-first.runErr, context.Canceled)
+}
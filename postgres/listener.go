@@ -0,0 +1,320 @@
+package pkgpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+const DefaultListenerBufferSize = 100
+
+// ErrListenerRunning is returned when Listener.Run is called more than once.
+var ErrListenerRunning = errors.New("listener is already running")
+
+// Notification is a message received on a channel the Listener subscribed to.
+type Notification struct {
+	// PID is the process ID of the server that sent the notification.
+	PID     uint32
+	Channel string
+	Payload string
+}
+
+// NotificationHandler handles notifications received by the Listener.
+type NotificationHandler func(ctx context.Context, n Notification)
+
+type ListenerConfig struct {
+	// Conn provides the DSN, the retry and session settings, the
+	// AfterConnect hook and the PasswordProvider used to (re)connect.
+	// Pool, health check settings and ConnOptions are ignored.
+	Conn SQLConnConfig
+	// Channels are the channels to listen to from the start.
+	Channels []string
+	// Handler is called for every notification. If it is nil, notifications
+	// are delivered to the channel returned by Listener.Notifications.
+	Handler NotificationHandler
+	// BufferSize is the size of the channel returned by Listener.Notifications.
+	BufferSize *int
+	// OnDisconnect is called when the connection is lost, before reconnecting.
+	OnDisconnect func(err error)
+}
+
+func (c *ListenerConfig) Validate() error {
+	err := c.Conn.Validate()
+	if err != nil {
+		return err
+	}
+
+	if c.BufferSize == nil {
+		c.BufferSize = pkgptr.Ptr(DefaultListenerBufferSize)
+	}
+
+	if *c.BufferSize < 0 {
+		return fmt.Errorf("buffer size must not be negative")
+	}
+
+	for _, ch := range c.Channels {
+		if ch == "" {
+			return fmt.Errorf("channel name must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// Listener subscribes to Postgres notifications with LISTEN. It uses a
+// dedicated connection that is reestablished with backoff when it is lost.
+// All the channels are listened to again after the reconnection.
+// Notifications sent while the Listener is disconnected are lost.
+type Listener struct {
+	cfg           ListenerConfig
+	pgxCfg        *pgx.ConnConfig
+	notifications chan Notification
+	wake          chan struct{}
+
+	mu       sync.Mutex
+	channels map[string]struct{}
+	running  bool
+}
+
+// NewListener creates a new Listener. Call Run to connect and start listening.
+func NewListener(cfg ListenerConfig) (*Listener, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid Listener config: %w", err)
+	}
+
+	pgxCfg, err := pgx.ParseConfig(cfg.Conn.DSN)
+	if err != nil {
+		return nil, err
+	}
+	configureConnConfig(pgxCfg, cfg.Conn.Session)
+
+	l := &Listener{
+		cfg:           cfg,
+		pgxCfg:        pgxCfg,
+		notifications: make(chan Notification, *cfg.BufferSize),
+		wake:          make(chan struct{}, 1),
+		channels:      make(map[string]struct{}),
+	}
+
+	for _, ch := range cfg.Channels {
+		l.channels[ch] = struct{}{}
+	}
+
+	return l, nil
+}
+
+// Notifications returns the channel notifications are delivered to when
+// no Handler is configured. The channel is closed when Run returns.
+func (l *Listener) Notifications() <-chan Notification {
+	return l.notifications
+}
+
+// Listen subscribes to the channels. If the Listener is running,
+// the subscription is applied to the current connection immediately.
+func (l *Listener) Listen(channels ...string) error {
+	l.mu.Lock()
+	for _, ch := range channels {
+		if ch == "" {
+			l.mu.Unlock()
+
+			return fmt.Errorf("channel name must not be empty")
+		}
+		l.channels[ch] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	l.notifyChange()
+
+	return nil
+}
+
+// Unlisten unsubscribes from the channels.
+func (l *Listener) Unlisten(channels ...string) {
+	l.mu.Lock()
+	for _, ch := range channels {
+		delete(l.channels, ch)
+	}
+	l.mu.Unlock()
+
+	l.notifyChange()
+}
+
+func (l *Listener) notifyChange() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run connects to the database and delivers notifications until the
+// context is canceled or the connection can't be reestablished within
+// the configured retry attempts.
+func (l *Listener) Run(ctx context.Context) error {
+	l.mu.Lock()
+	if l.running {
+		l.mu.Unlock()
+
+		return ErrListenerRunning
+	}
+	l.running = true
+	l.mu.Unlock()
+
+	defer close(l.notifications)
+
+	for {
+		conn, err := l.connect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect listener: %w", err)
+		}
+
+		err = l.receive(ctx, conn)
+		_ = conn.Close(context.Background())
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if l.cfg.OnDisconnect != nil {
+			l.cfg.OnDisconnect(err)
+		}
+	}
+}
+
+func (l *Listener) connect(ctx context.Context) (*pgx.Conn, error) {
+	var conn *pgx.Conn
+	err := retryConnect(ctx, retryConnectConfig{
+		attempts:  *l.cfg.Conn.RetryConnectAttempts,
+		delay:     *l.cfg.Conn.RetryConnectDelay,
+		maxDelay:  *l.cfg.Conn.RetryConnectMaxDelay,
+		maxJitter: *l.cfg.Conn.RetryConnectMaxJitter,
+		onRetry:   l.cfg.Conn.OnRetry,
+	}, func() error {
+		connCfg := l.pgxCfg.Copy()
+		connErr := beforeConnect(l.cfg.Conn.PasswordProvider)(ctx, connCfg)
+		if connErr != nil {
+			return connErr
+		}
+
+		conn, connErr = pgx.ConnectConfig(ctx, connCfg)
+		if connErr != nil {
+			return connErr
+		}
+
+		connErr = afterConnect(l.cfg.Conn.AfterConnect)(ctx, conn)
+		if connErr != nil {
+			_ = conn.Close(ctx)
+
+			return connErr
+		}
+
+		return nil
+	})
+
+	return conn, err
+}
+
+// receive waits for notifications on conn until the connection fails
+// or the context is canceled.
+func (l *Listener) receive(ctx context.Context, conn *pgx.Conn) error {
+	listening := make(map[string]struct{})
+
+	for {
+		err := l.syncChannels(ctx, conn, listening)
+		if err != nil {
+			return err
+		}
+
+		waitCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-l.wake:
+				cancel()
+			case <-waitCtx.Done():
+			}
+		}()
+
+		n, err := conn.WaitForNotification(waitCtx)
+		woken := waitCtx.Err() != nil && ctx.Err() == nil
+		cancel()
+
+		if err != nil {
+			if woken {
+				continue
+			}
+
+			return err
+		}
+
+		l.deliver(ctx, Notification{
+			PID:     n.PID,
+			Channel: n.Channel,
+			Payload: n.Payload,
+		})
+	}
+}
+
+// syncChannels runs LISTEN and UNLISTEN on conn so the listening
+// channels match the Listener's channels.
+func (l *Listener) syncChannels(ctx context.Context, conn *pgx.Conn, listening map[string]struct{}) error {
+	l.mu.Lock()
+	var listen, unlisten []string
+	for ch := range l.channels {
+		if _, ok := listening[ch]; !ok {
+			listen = append(listen, ch)
+		}
+	}
+	for ch := range listening {
+		if _, ok := l.channels[ch]; !ok {
+			unlisten = append(unlisten, ch)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, ch := range listen {
+		_, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{ch}.Sanitize())
+		if err != nil {
+			return fmt.Errorf("failed to listen to channel %s: %w", ch, err)
+		}
+		listening[ch] = struct{}{}
+	}
+
+	for _, ch := range unlisten {
+		_, err := conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{ch}.Sanitize())
+		if err != nil {
+			return fmt.Errorf("failed to unlisten channel %s: %w", ch, err)
+		}
+		delete(listening, ch)
+	}
+
+	return nil
+}
+
+func (l *Listener) deliver(ctx context.Context, n Notification) {
+	if l.cfg.Handler != nil {
+		l.cfg.Handler(ctx, n)
+
+		return
+	}
+
+	select {
+	case l.notifications <- n:
+	case <-ctx.Done():
+	}
+}
+
+// Notify sends a notification to the channel with pg_notify. When e is
+// a transaction, the notification is delivered after the commit.
+func Notify(ctx context.Context, e pkgsql.Execer, channel, payload string) error {
+	_, err := e.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, payload)
+	if err != nil {
+		return fmt.Errorf("failed to notify channel %s: %w", channel, err)
+	}
+
+	return nil
+}
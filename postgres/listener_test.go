@@ -0,0 +1,107 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgpostgrestest "github.com/amanbolat/pkg/postgres/postgrestest"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer starts a Postgres server for the test or skips the test
+// if neither Docker nor the local binaries are available.
+func startServer(t *testing.T) *pkgpostgrestest.Server {
+	t.Helper()
+
+	if testing.Short() {
+		t.Skip("skipping postgres test in short mode")
+	}
+
+	srv, err := pkgpostgrestest.StartServer(context.Background(), pkgpostgrestest.ServerConfig{})
+	if err != nil {
+		t.Skipf("postgres is not available: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, srv.Close(context.Background()))
+	})
+
+	return srv
+}
+
+func TestListener_Reconnect(t *testing.T) {
+	srv := startServer(t)
+	dsn := srv.NewDatabase(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	conn, err := pkgpostgres.NewSQLConn(ctx, pkgpostgres.SQLConnConfig{DSN: dsn})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	disconnected := make(chan error, 1)
+	listener, err := pkgpostgres.NewListener(pkgpostgres.ListenerConfig{
+		Conn: pkgpostgres.SQLConnConfig{
+			DSN:               dsn,
+			RetryConnectDelay: pkgptr.Ptr(time.Millisecond * 100),
+			Session:           pkgpostgres.SessionSettings{ApplicationName: pkgptr.Ptr("listener_test")},
+		},
+		Channels: []string{"events"},
+		OnDisconnect: func(err error) {
+			disconnected <- err
+		},
+	})
+	require.NoError(t, err)
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- listener.Run(ctx)
+	}()
+
+	// LISTEN runs asynchronously, so the notification is repeated until
+	// the listener receives it.
+	receive := func(payload string) {
+		t.Helper()
+
+		ticker := time.NewTicker(time.Millisecond * 100)
+		defer ticker.Stop()
+
+		for {
+			require.NoError(t, pkgpostgres.Notify(ctx, conn, "events", payload))
+
+			select {
+			case n := <-listener.Notifications():
+				if n.Payload == payload {
+					assert.Equal(t, "events", n.Channel)
+
+					return
+				}
+			case <-ticker.C:
+			case <-ctx.Done():
+				t.Fatalf("notification %s was not received", payload)
+			}
+		}
+	}
+
+	receive("before")
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE application_name = 'listener_test'`)
+	require.NoError(t, err)
+
+	select {
+	case err := <-disconnected:
+		assert.Error(t, err)
+	case <-ctx.Done():
+		t.Fatal("listener didn't notice the terminated connection")
+	}
+
+	// The channel is listened to again after the reconnection.
+	receive("after")
+
+	cancel()
+	assert.ErrorIs(t, <-runErr, context.Canceled)
+}
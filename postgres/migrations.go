@@ -0,0 +1,22 @@
+package pkgpostgres
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// embeddedMigrations returns the migrations of the subsystem in the given format.
+func embeddedMigrations(name string, format pkgsql.MigrationFormat) (fs.FS, error) {
+	if !format.IsValid() {
+		return nil, fmt.Errorf("unknown migration format %d", format)
+	}
+
+	return fs.Sub(migrationsFS, path.Join("migrations", name, format.String()))
+}
@@ -0,0 +1,20 @@
+CREATE TABLE jobs
+(
+    id           bigserial PRIMARY KEY,
+    queue        text        NOT NULL,
+    kind         text        NOT NULL,
+    payload      bytea       NOT NULL,
+    unique_key   text,
+    status       text        NOT NULL DEFAULT 'pending',
+    attempts     int         NOT NULL DEFAULT 0,
+    max_attempts int         NOT NULL,
+    run_at       timestamptz NOT NULL DEFAULT now(),
+    locked_until timestamptz,
+    last_error   text,
+    created_at   timestamptz NOT NULL DEFAULT now(),
+    CONSTRAINT jobs_status_check CHECK (status IN ('pending', 'running', 'dead'))
+);
+
+CREATE INDEX jobs_pending_idx ON jobs (queue, run_at, id) WHERE status = 'pending';
+CREATE INDEX jobs_running_idx ON jobs (queue, locked_until) WHERE status = 'running';
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (queue, unique_key) WHERE unique_key IS NOT NULL AND status <> 'dead';
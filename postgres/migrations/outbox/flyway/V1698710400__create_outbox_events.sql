@@ -0,0 +1,19 @@
+CREATE TABLE outbox_events
+(
+    id              bigserial PRIMARY KEY,
+    aggregate_type  text        NOT NULL,
+    aggregate_id    text        NOT NULL,
+    event_type      text        NOT NULL,
+    payload         bytea       NOT NULL,
+    headers         jsonb       NOT NULL DEFAULT '{}',
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    attempts        int         NOT NULL DEFAULT 0,
+    next_attempt_at timestamptz NOT NULL DEFAULT now(),
+    last_error      text,
+    published_at    timestamptz,
+    failed_at       timestamptz
+);
+
+CREATE INDEX outbox_events_pending_idx ON outbox_events (id) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX outbox_events_aggregate_idx ON outbox_events (aggregate_type, aggregate_id, id) WHERE published_at IS NULL AND failed_at IS NULL;
+CREATE INDEX outbox_events_published_at_idx ON outbox_events (published_at) WHERE published_at IS NOT NULL;
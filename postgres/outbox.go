@@ -0,0 +1,376 @@
+package pkgpostgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+const (
+	DefaultOutboxBatchSize       = 100
+	DefaultOutboxPollInterval    = time.Second
+	DefaultOutboxMaxAttempts     = 10
+	DefaultOutboxRetryDelay      = time.Second
+	DefaultOutboxMaxRetryDelay   = time.Minute * 5
+	DefaultOutboxRetention       = time.Hour * 24 * 7
+	DefaultOutboxCleanupInterval = time.Hour
+)
+
+// OutboxMigrations returns the migrations that create the outbox_events
+// table in the given format. The returned fs.FS can be used as
+// pkgsql.MigratorConfig.MigrationsFs with MigrationsDir set to ".", or its
+// files can be copied to the application migrations. When the migrations
+// are applied with a separate Migrator, set x-migrations-table in the DSN,
+// so they don't share the version table with the application migrations.
+func OutboxMigrations(format pkgsql.MigrationFormat) (fs.FS, error) {
+	return embeddedMigrations("outbox", format)
+}
+
+// OutboxMessage is an event added to the outbox.
+type OutboxMessage struct {
+	// AggregateType and AggregateID identify the entity the event belongs to.
+	// Events of the same aggregate are published in the order they were added.
+	AggregateType string
+	AggregateID   string
+	EventType     string
+	Payload       []byte
+	Headers       map[string]string
+}
+
+// OutboxEvent is an event stored in the outbox.
+type OutboxEvent struct {
+	ID            int64
+	AggregateType string
+	AggregateID   string
+	EventType     string
+	Payload       []byte
+	Headers       map[string]string
+	CreatedAt     time.Time
+	// Attempts is the number of the previous failed attempts to publish the event.
+	Attempts int
+}
+
+// Publisher publishes the outbox events to a message broker.
+// An event is published at least once, so the consumers must be idempotent.
+type Publisher interface {
+	Publish(ctx context.Context, event OutboxEvent) error
+}
+
+// PublisherFunc is a function that implements Publisher.
+type PublisherFunc func(ctx context.Context, event OutboxEvent) error
+
+// Publish calls f(ctx, event).
+func (f PublisherFunc) Publish(ctx context.Context, event OutboxEvent) error {
+	return f(ctx, event)
+}
+
+// AddOutboxMessages adds the messages to the outbox. tx should be the
+// pkgsql.Tx that changes the business data, so the messages are published
+// only if the transaction is committed. Inside pkgsql.AtomicStore it is
+// the TableOperator passed to NewStoreFunc.
+//
+// Example:
+//
+//	func (s *Store) CreateOrder(ctx context.Context, order Order) error {
+//	    _, err := s.to.ExecContext(ctx, "INSERT INTO orders ...")
+//	    if err != nil {
+//	        return err
+//	    }
+//
+//	    return pkgpostgres.AddOutboxMessages(ctx, s.to, pkgpostgres.OutboxMessage{
+//	        AggregateType: "order",
+//	        AggregateID:   order.ID,
+//	        EventType:     "order_created",
+//	        Payload:       payload,
+//	    })
+//	}
+func AddOutboxMessages(ctx context.Context, tx pkgsql.Execer, messages ...OutboxMessage) error {
+	for _, msg := range messages {
+		headers := msg.Headers
+		if headers == nil {
+			headers = map[string]string{}
+		}
+
+		headersJSON, err := json.Marshal(headers)
+		if err != nil {
+			return fmt.Errorf("failed to marshal outbox message headers: %w", err)
+		}
+
+		payload := msg.Payload
+		if payload == nil {
+			payload = []byte{}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO outbox_events (aggregate_type, aggregate_id, event_type, payload, headers)
+			VALUES ($1, $2, $3, $4, $5)`,
+			msg.AggregateType, msg.AggregateID, msg.EventType, payload, headersJSON,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add outbox message: %w", err)
+		}
+	}
+
+	return nil
+}
+
+type OutboxRelayConfig struct {
+	// BatchSize is the maximum number of events claimed at once.
+	BatchSize *int
+	// PollInterval is the time to wait when there are no events to publish.
+	PollInterval *time.Duration
+	// MaxAttempts is the number of attempts to publish an event, after which
+	// the event is marked as failed and is not published anymore.
+	MaxAttempts *int
+	// RetryDelay is the delay before the first retry. It doubles after every attempt.
+	RetryDelay *time.Duration
+	// MaxRetryDelay caps the delay between retries.
+	MaxRetryDelay *time.Duration
+	// Retention is how long the published events are kept before cleanup.
+	Retention *time.Duration
+	// CleanupInterval is the time between two cleanups.
+	CleanupInterval *time.Duration
+	// OnError is called with the errors that don't stop the relay.
+	OnError func(err error)
+}
+
+func (c *OutboxRelayConfig) Validate() error {
+	if c.BatchSize == nil {
+		c.BatchSize = pkgptr.Ptr(DefaultOutboxBatchSize)
+	}
+
+	if c.PollInterval == nil {
+		c.PollInterval = pkgptr.Ptr(DefaultOutboxPollInterval)
+	}
+
+	if c.MaxAttempts == nil {
+		c.MaxAttempts = pkgptr.Ptr(DefaultOutboxMaxAttempts)
+	}
+
+	if c.RetryDelay == nil {
+		c.RetryDelay = pkgptr.Ptr(DefaultOutboxRetryDelay)
+	}
+
+	if c.MaxRetryDelay == nil {
+		c.MaxRetryDelay = pkgptr.Ptr(DefaultOutboxMaxRetryDelay)
+	}
+
+	if c.Retention == nil {
+		c.Retention = pkgptr.Ptr(DefaultOutboxRetention)
+	}
+
+	if c.CleanupInterval == nil {
+		c.CleanupInterval = pkgptr.Ptr(DefaultOutboxCleanupInterval)
+	}
+
+	if *c.BatchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	if *c.MaxAttempts <= 0 {
+		return fmt.Errorf("max attempts must be positive")
+	}
+
+	if *c.PollInterval <= 0 || *c.CleanupInterval <= 0 {
+		return fmt.Errorf("poll and cleanup intervals must be positive")
+	}
+
+	return nil
+}
+
+// OutboxRelay publishes the events from the outbox. Several relays can run
+// concurrently, events are claimed with FOR UPDATE SKIP LOCKED.
+// An event is not published until all the previous events of the same
+// aggregate are either published or failed.
+type OutboxRelay struct {
+	db        pkgsql.Database
+	publisher Publisher
+	cfg       OutboxRelayConfig
+}
+
+// NewOutboxRelay creates a new OutboxRelay. Call Run to start it.
+func NewOutboxRelay(db pkgsql.Database, publisher Publisher, cfg OutboxRelayConfig) (*OutboxRelay, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid OutboxRelay config: %w", err)
+	}
+
+	return &OutboxRelay{
+		db:        db,
+		publisher: publisher,
+		cfg:       cfg,
+	}, nil
+}
+
+// Run publishes the events and cleans up the published ones until
+// the context is canceled.
+func (r *OutboxRelay) Run(ctx context.Context) error {
+	pollTicker := time.NewTicker(*r.cfg.PollInterval)
+	defer pollTicker.Stop()
+
+	cleanupTicker := time.NewTicker(*r.cfg.CleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		n, err := r.PublishBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.onError(err)
+		}
+
+		// Keep publishing without waiting while the batches are full.
+		if err == nil && n == *r.cfg.BatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-cleanupTicker.C:
+			_, err = r.Cleanup(ctx)
+			if err != nil && ctx.Err() == nil {
+				r.onError(err)
+			}
+		case <-pollTicker.C:
+		}
+	}
+}
+
+func (r *OutboxRelay) onError(err error) {
+	if r.cfg.OnError != nil {
+		r.cfg.OnError(err)
+	}
+}
+
+// PublishBatch claims a batch of events, publishes them and returns
+// the number of claimed events.
+func (r *OutboxRelay) PublishBatch(ctx context.Context) (n int, err error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{
+		Isolation: sql.LevelReadCommitted,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		err = pkgsql.EndTx(tx, err)
+	}()
+
+	events, err := r.claim(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		pubErr := r.publisher.Publish(ctx, event)
+		if pubErr == nil {
+			_, err = tx.ExecContext(ctx, `UPDATE outbox_events SET published_at = now() WHERE id = $1`, event.ID)
+		} else {
+			err = r.markFailedAttempt(ctx, tx, event, pubErr)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to update outbox event %d: %w", event.ID, err)
+		}
+	}
+
+	return len(events), nil
+}
+
+func (r *OutboxRelay) claim(ctx context.Context, tx *sql.Tx) ([]OutboxEvent, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT e.id, e.aggregate_type, e.aggregate_id, e.event_type, e.payload, e.headers, e.created_at, e.attempts
+		FROM outbox_events e
+		WHERE e.published_at IS NULL
+		  AND e.failed_at IS NULL
+		  AND e.next_attempt_at <= now()
+		  AND NOT EXISTS (
+			SELECT 1
+			FROM outbox_events p
+			WHERE p.aggregate_type = e.aggregate_type
+			  AND p.aggregate_id = e.aggregate_id
+			  AND p.published_at IS NULL
+			  AND p.failed_at IS NULL
+			  AND p.id < e.id
+		  )
+		ORDER BY e.id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`,
+		*r.cfg.BatchSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []OutboxEvent
+	for rows.Next() {
+		var (
+			event   OutboxEvent
+			headers []byte
+		)
+
+		err = rows.Scan(&event.ID, &event.AggregateType, &event.AggregateID, &event.EventType,
+			&event.Payload, &headers, &event.CreatedAt, &event.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+
+		err = json.Unmarshal(headers, &event.Headers)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal headers of outbox event %d: %w", event.ID, err)
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (r *OutboxRelay) markFailedAttempt(ctx context.Context, tx *sql.Tx, event OutboxEvent, pubErr error) error {
+	attempts := event.Attempts + 1
+	if attempts >= *r.cfg.MaxAttempts {
+		_, err := tx.ExecContext(ctx, `
+			UPDATE outbox_events SET attempts = $2, last_error = $3, failed_at = now() WHERE id = $1`,
+			event.ID, attempts, pubErr.Error(),
+		)
+
+		return err
+	}
+
+	_, err := tx.ExecContext(ctx, `
+		UPDATE outbox_events
+		SET attempts = $2, last_error = $3, next_attempt_at = now() + $4 * interval '1 millisecond'
+		WHERE id = $1`,
+		event.ID, attempts, pubErr.Error(), backoffDelay(attempts, *r.cfg.RetryDelay, *r.cfg.MaxRetryDelay).Milliseconds(),
+	)
+
+	return err
+}
+
+// Cleanup deletes the events published earlier than the retention period
+// and returns the number of deleted events. Failed events are kept.
+func (r *OutboxRelay) Cleanup(ctx context.Context) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		DELETE FROM outbox_events WHERE published_at < now() - $1 * interval '1 millisecond'`,
+		r.cfg.Retention.Milliseconds(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to clean up outbox events: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get number of deleted outbox events: %w", err)
+	}
+
+	return n, nil
+}
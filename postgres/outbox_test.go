@@ -0,0 +1,35 @@
+package pkgpostgres_test
+
+import (
+	"io/fs"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxMigrations(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []pkgsql.MigrationFormat{pkgsql.MigrationFormatFlyway, pkgsql.MigrationFormatGomigrate} {
+		fsys, err := pkgpostgres.OutboxMigrations(format)
+		require.NoError(t, err)
+
+		files, err := fs.Glob(fsys, "*.sql")
+		require.NoError(t, err)
+		assert.Len(t, files, 2, format.String())
+
+		issues, err := pkgsql.LintMigrations(pkgsql.MigratorConfig{
+			MigrationsFs:  fsys,
+			MigrationsDir: ".",
+			Format:        format,
+		}, pkgsql.LintOptions{})
+		require.NoError(t, err)
+		assert.Empty(t, issues, format.String())
+	}
+
+	_, err := pkgpostgres.OutboxMigrations(pkgsql.MigrationFormat(42))
+	assert.Error(t, err)
+}
@@ -0,0 +1,164 @@
+package pkgpostgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/jackc/pgx/v5"
+)
+
+// DefaultPasswordPollInterval is how often FilePasswordProvider checks
+// the file for a new password if PollInterval is not set.
+const DefaultPasswordPollInterval = time.Second * 10
+
+// PasswordProvider provides the password for every new connection, so
+// the rotated passwords and short-lived tokens are picked up without
+// recreating the connection pool. The password in the DSN is ignored.
+type PasswordProvider interface {
+	Password(ctx context.Context) (string, error)
+}
+
+// PasswordProviderFunc is a function that implements PasswordProvider.
+type PasswordProviderFunc func(ctx context.Context) (string, error)
+
+func (f PasswordProviderFunc) Password(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// beforeConnect returns the hook that sets the password from the provider.
+func beforeConnect(provider PasswordProvider) func(ctx context.Context, connCfg *pgx.ConnConfig) error {
+	return func(ctx context.Context, connCfg *pgx.ConnConfig) error {
+		if provider == nil {
+			return nil
+		}
+
+		password, err := provider.Password(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get password: %w", err)
+		}
+		connCfg.Password = password
+
+		return nil
+	}
+}
+
+type FilePasswordProviderConfig struct {
+	// Path is the file with the password, e.g. a mounted secret.
+	// Trailing newlines are trimmed.
+	Path string
+	// PollInterval is the time between two checks of the file.
+	PollInterval *time.Duration
+	// OnChange is called after the changed password is loaded.
+	OnChange func()
+	// OnError is called when the file can't be read. The last loaded
+	// password is used until the file is read successfully.
+	OnError func(err error)
+}
+
+func (c *FilePasswordProviderConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+
+	if c.PollInterval == nil {
+		c.PollInterval = pkgptr.Ptr(DefaultPasswordPollInterval)
+	}
+
+	if *c.PollInterval <= 0 {
+		return fmt.Errorf("poll interval must be positive")
+	}
+
+	return nil
+}
+
+// FilePasswordProvider is PasswordProvider that reads the password from
+// a file and reloads it when the file changes. Files are polled instead
+// of watched with inotify, because the mounted secrets are often replaced
+// by swapping symlinks, which doesn't produce events for the file itself.
+type FilePasswordProvider struct {
+	cfg FilePasswordProviderConfig
+
+	mu       sync.RWMutex
+	password string
+}
+
+// NewFilePasswordProvider creates a new FilePasswordProvider and loads
+// the password. Call Run to reload the password when the file changes.
+func NewFilePasswordProvider(cfg FilePasswordProviderConfig) (*FilePasswordProvider, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid FilePasswordProvider config: %w", err)
+	}
+
+	p := &FilePasswordProvider{cfg: cfg}
+
+	_, err = p.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Password returns the last loaded password.
+func (p *FilePasswordProvider) Password(_ context.Context) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.password, nil
+}
+
+// Reload reads the file and reports whether the password has changed.
+func (p *FilePasswordProvider) Reload() (bool, error) {
+	b, err := os.ReadFile(p.cfg.Path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read password file: %w", err)
+	}
+
+	password := string(bytes.TrimRight(b, "\r\n"))
+	if password == "" {
+		return false, fmt.Errorf("password file %s is empty", p.cfg.Path)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if password == p.password {
+		return false, nil
+	}
+	p.password = password
+
+	return true, nil
+}
+
+// Run reloads the password every PollInterval until the context is canceled.
+func (p *FilePasswordProvider) Run(ctx context.Context) error {
+	ticker := time.NewTicker(*p.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		changed, err := p.Reload()
+		if err != nil {
+			if p.cfg.OnError != nil {
+				p.cfg.OnError(err)
+			}
+
+			continue
+		}
+
+		if changed && p.cfg.OnChange != nil {
+			p.cfg.OnChange()
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package pkgpostgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+const (
+	DefaultMinConns        = 0
+	DefaultMaxConnIdleTime = time.Minute * 30
+)
+
+// The *sql.DB returned by SQLDB can be used as pkgsql.Database.
+var _ pkgsql.Database = (*sql.DB)(nil)
+
+// PoolConn is a wrapper around pgxpool.Pool that gives access to pgx
+// native features like CopyFrom, batches and LISTEN.
+type PoolConn struct {
+	*pgxpool.Pool
+	db *sql.DB
+}
+
+type PoolConnConfig struct {
+	DSN string
+	// RetryConnectAttempts is the maximum number of connection attempts.
+	// It must be positive.
+	RetryConnectAttempts *uint
+	// RetryConnectDelay is the delay after the first failed attempt.
+	RetryConnectDelay *time.Duration
+	// RetryConnectMaxDelay caps the exponentially growing delay between attempts.
+	RetryConnectMaxDelay *time.Duration
+	// RetryConnectMaxJitter is the maximum random duration added to the delay
+	// after it is capped by RetryConnectMaxDelay.
+	RetryConnectMaxJitter *time.Duration
+	// OnRetry is called after every failed connection attempt.
+	OnRetry         OnRetryFunc
+	MaxConns        *int32
+	MinConns        *int32
+	MaxConnLifetime *time.Duration
+	MaxConnIdleTime *time.Duration
+	// Session are the session parameters set on every new connection.
+	Session SessionSettings
+	// AfterConnect is called on every new connection after the custom
+	// types, e.g. pkgdecimal.Decimal, are registered.
+	AfterConnect AfterConnectFunc
+	// PasswordProvider is consulted for the password on every new connection.
+	PasswordProvider PasswordProvider
+}
+
+func (c *PoolConnConfig) Validate() error {
+	if c.DSN == "" {
+		return fmt.Errorf("dsn is required")
+	}
+
+	if c.RetryConnectAttempts == nil {
+		c.RetryConnectAttempts = pkgptr.Ptr(uint(DefaultRetryConnectAttempts))
+	}
+
+	if *c.RetryConnectAttempts == 0 {
+		return fmt.Errorf("retry connect attempts must be positive")
+	}
+
+	if c.RetryConnectDelay == nil {
+		c.RetryConnectDelay = pkgptr.Ptr(DefaultRetryConnectDelay)
+	}
+
+	if c.RetryConnectMaxDelay == nil {
+		c.RetryConnectMaxDelay = pkgptr.Ptr(DefaultRetryConnectMaxDelay)
+	}
+
+	if c.RetryConnectMaxJitter == nil {
+		c.RetryConnectMaxJitter = pkgptr.Ptr(DefaultRetryConnectMaxJitter)
+	}
+
+	if c.MaxConns == nil {
+		c.MaxConns = pkgptr.Ptr(int32(DefaultMaxOpenConns))
+	}
+
+	if c.MinConns == nil {
+		c.MinConns = pkgptr.Ptr(int32(DefaultMinConns))
+	}
+
+	if c.MaxConnLifetime == nil {
+		c.MaxConnLifetime = pkgptr.Ptr(DefaultConnMaxLifetime)
+	}
+
+	if c.MaxConnIdleTime == nil {
+		c.MaxConnIdleTime = pkgptr.Ptr(DefaultMaxConnIdleTime)
+	}
+
+	if *c.MinConns > *c.MaxConns {
+		return fmt.Errorf("min conns %d is greater than max conns %d", *c.MinConns, *c.MaxConns)
+	}
+
+	err := c.Session.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid session settings: %w", err)
+	}
+
+	return nil
+}
+
+// NewPoolConn creates and returns a new postgres connection pool.
+// The function ensures that the connection is established correctly
+// by send a ping request to the database.
+func NewPoolConn(ctx context.Context, cfg PoolConnConfig) (*PoolConn, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid PoolConn config: %w", err)
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+
+	poolCfg.MaxConns = *cfg.MaxConns
+	poolCfg.MinConns = *cfg.MinConns
+	poolCfg.MaxConnLifetime = *cfg.MaxConnLifetime
+	poolCfg.MaxConnIdleTime = *cfg.MaxConnIdleTime
+	poolCfg.AfterConnect = afterConnect(cfg.AfterConnect)
+	poolCfg.BeforeConnect = beforeConnect(cfg.PasswordProvider)
+	configureConnConfig(poolCfg.ConnConfig, cfg.Session)
+
+	var pool *pgxpool.Pool
+	err = retryConnect(ctx, retryConnectConfig{
+		attempts:  *cfg.RetryConnectAttempts,
+		delay:     *cfg.RetryConnectDelay,
+		maxDelay:  *cfg.RetryConnectMaxDelay,
+		maxJitter: *cfg.RetryConnectMaxJitter,
+		onRetry:   cfg.OnRetry,
+	}, func() error {
+		var connErr error
+		pool, connErr = pgxpool.NewWithConfig(ctx, poolCfg)
+		if connErr != nil {
+			return connErr
+		}
+
+		connErr = pool.Ping(ctx)
+		if connErr != nil {
+			pool.Close()
+			return connErr
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	c := &PoolConn{
+		Pool: pool,
+		db:   stdlib.OpenDBFromPool(pool),
+	}
+
+	return c, nil
+}
+
+// SQLDB returns *sql.DB that acquires connections from the pool.
+// It satisfies pkgsql.Database, so it can be used with pkgsql.AtomicStore.
+func (c *PoolConn) SQLDB() *sql.DB {
+	return c.db
+}
+
+// Close closes the *sql.DB returned by SQLDB and all the connections in the pool.
+func (c *PoolConn) Close() error {
+	err := c.db.Close()
+	c.Pool.Close()
+
+	return err
+}
@@ -0,0 +1,52 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolConnConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	var cfg pkgpostgres.PoolConnConfig
+	assert.Error(t, cfg.Validate())
+
+	cfg = pkgpostgres.PoolConnConfig{DSN: "postgres://app@127.0.0.1:1/app"}
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, uint(pkgpostgres.DefaultRetryConnectAttempts), *cfg.RetryConnectAttempts)
+	assert.Equal(t, pkgpostgres.DefaultRetryConnectDelay, *cfg.RetryConnectDelay)
+	assert.Equal(t, pkgpostgres.DefaultRetryConnectMaxDelay, *cfg.RetryConnectMaxDelay)
+	assert.Equal(t, pkgpostgres.DefaultRetryConnectMaxJitter, *cfg.RetryConnectMaxJitter)
+	assert.Equal(t, int32(pkgpostgres.DefaultMaxOpenConns), *cfg.MaxConns)
+	assert.Equal(t, int32(pkgpostgres.DefaultMinConns), *cfg.MinConns)
+	assert.Equal(t, pkgpostgres.DefaultConnMaxLifetime, *cfg.MaxConnLifetime)
+	assert.Equal(t, pkgpostgres.DefaultMaxConnIdleTime, *cfg.MaxConnIdleTime)
+
+	cfg = pkgpostgres.PoolConnConfig{
+		DSN:      "postgres://app@127.0.0.1:1/app",
+		MaxConns: pkgptr.Ptr(int32(2)),
+		MinConns: pkgptr.Ptr(int32(3)),
+	}
+	assert.Error(t, cfg.Validate())
+
+	cfg = pkgpostgres.PoolConnConfig{
+		DSN:                  "postgres://app@127.0.0.1:1/app",
+		RetryConnectAttempts: pkgptr.Ptr(uint(0)),
+	}
+	assert.Error(t, cfg.Validate())
+}
+
+func TestNewPoolConn(t *testing.T) {
+	t.Parallel()
+
+	_, err := pkgpostgres.NewPoolConn(context.Background(), pkgpostgres.PoolConnConfig{
+		DSN:                  "postgres://app@127.0.0.1:1/app",
+		RetryConnectAttempts: pkgptr.Ptr(uint(1)),
+	})
+	assert.Error(t, err)
+}
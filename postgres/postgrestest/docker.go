@@ -0,0 +1,31 @@
+package pkgpostgrestest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/testcontainers/testcontainers-go"
+	pgtestctr "github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+// startDocker runs the server in a container and returns its connection string.
+func startDocker(ctx context.Context, cfg ServerConfig) (string, func(ctx context.Context) error, error) {
+	pgCtr, err := pgtestctr.RunContainer(ctx,
+		testcontainers.WithImage(*cfg.Image),
+		pgtestctr.WithUsername(*cfg.User),
+		pgtestctr.WithPassword(*cfg.Password),
+		pgtestctr.WithDatabase(*cfg.User),
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to run postgres container: %w", err)
+	}
+
+	dsn, err := pgCtr.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		_ = pgCtr.Terminate(context.Background())
+
+		return "", nil, fmt.Errorf("failed to get postgres connection string: %w", err)
+	}
+
+	return dsn, pgCtr.Terminate, nil
+}
@@ -0,0 +1,174 @@
+package pkgpostgrestest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// startLocal initializes a cluster in a temporary directory with initdb,
+// starts postgres on a free port and returns its connection string.
+func startLocal(ctx context.Context, cfg ServerConfig) (string, func(ctx context.Context) error, error) {
+	initdb, err := lookPostgresBinary("initdb", cfg.BinDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	postgres, err := lookPostgresBinary("postgres", cfg.BinDir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "pkgpostgrestest")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	pwFile := filepath.Join(dir, "pwfile")
+	err = os.WriteFile(pwFile, []byte(*cfg.Password), 0o600)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return "", nil, fmt.Errorf("failed to write password file: %w", err)
+	}
+
+	dataDir := filepath.Join(dir, "data")
+	out, err := exec.CommandContext(ctx, initdb,
+		"-D", dataDir,
+		"-U", *cfg.User,
+		"--pwfile", pwFile,
+		"--auth", "md5",
+		"-E", "UTF8",
+		"--no-sync",
+	).CombinedOutput()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return "", nil, fmt.Errorf("failed to run initdb: %w: %s", err, bytes.TrimSpace(out))
+	}
+
+	port, err := freePort()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return "", nil, err
+	}
+
+	cmd := exec.Command(postgres,
+		"-D", dataDir,
+		"-p", strconv.Itoa(port),
+		"-k", dir,
+		"-c", "listen_addresses=127.0.0.1",
+		"-c", "fsync=off",
+		"-c", "synchronous_commit=off",
+		"-c", "full_page_writes=off",
+	)
+	var logs bytes.Buffer
+	cmd.Stdout = &logs
+	cmd.Stderr = &logs
+
+	err = cmd.Start()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+
+		return "", nil, fmt.Errorf("failed to start postgres: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	stop := func(ctx context.Context) error {
+		defer os.RemoveAll(dir)
+
+		// SIGINT is the fast shutdown mode.
+		_ = cmd.Process.Signal(os.Interrupt)
+
+		select {
+		case <-exited:
+			return nil
+		case <-ctx.Done():
+		case <-time.After(time.Second * 30):
+		}
+
+		_ = cmd.Process.Kill()
+		<-exited
+
+		return errors.New("postgres didn't stop in time and was killed")
+	}
+
+	// Fail fast instead of waiting for the startup timeout if postgres exits.
+	select {
+	case err = <-exited:
+		_ = os.RemoveAll(dir)
+
+		return "", nil, fmt.Errorf("postgres exited: %w: %s", err, bytes.TrimSpace(logs.Bytes()))
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(*cfg.User, *cfg.Password),
+		Host:     net.JoinHostPort("127.0.0.1", strconv.Itoa(port)),
+		Path:     "/postgres",
+		RawQuery: "sslmode=disable",
+	}
+
+	return dsn.String(), stop, nil
+}
+
+// lookPostgresBinary finds the binary in binDir if it is not nil,
+// otherwise in PATH or in the bin directory reported by pg_config.
+func lookPostgresBinary(name string, binDir *string) (string, error) {
+	if binDir != nil {
+		path := filepath.Join(*binDir, name)
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("failed to find %s: %w", name, err)
+		}
+
+		return path, nil
+	}
+
+	path, err := exec.LookPath(name)
+	if err == nil {
+		return path, nil
+	}
+
+	pgConfig, pgConfigErr := exec.LookPath("pg_config")
+	if pgConfigErr != nil {
+		return "", fmt.Errorf("failed to find %s: %w", name, err)
+	}
+
+	out, pgConfigErr := exec.Command(pgConfig, "--bindir").Output()
+	if pgConfigErr != nil {
+		return "", fmt.Errorf("failed to find %s: %w", name, errors.Join(err, pgConfigErr))
+	}
+
+	path = filepath.Join(strings.TrimSpace(string(out)), name)
+	if _, statErr := os.Stat(path); statErr != nil {
+		return "", fmt.Errorf("failed to find %s: %w", name, errors.Join(err, statErr))
+	}
+
+	return path, nil
+}
+
+// freePort returns a TCP port that is free at the moment.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find free port: %w", err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
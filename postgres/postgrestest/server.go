@@ -0,0 +1,325 @@
+// Package pkgpostgrestest starts throwaway Postgres servers for tests and tools.
+//
+// Example:
+//
+//	func TestStore(t *testing.T) {
+//	    srv := pkgpostgrestest.Start(t, pkgpostgrestest.ServerConfig{
+//	        Migrations: &pkgsql.MigratorConfig{MigrationsDir: "../migrations"},
+//	    })
+//
+//	    dsn := srv.NewDatabase(t) // migrated database used only by this test
+//	}
+package pkgpostgrestest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultImage          = "docker.io/postgres:15.2-alpine"
+	DefaultUser           = "postgres"
+	DefaultPassword       = "postgres"
+	DefaultStartupTimeout = time.Minute
+	templateDatabase      = "pkgtest_template"
+	startupRetryDelay     = time.Millisecond * 200
+)
+
+// Backend is a way to run the Postgres server.
+type Backend string
+
+const (
+	// BackendAuto uses Docker if it is available, otherwise the local binaries.
+	BackendAuto Backend = "auto"
+	// BackendDocker runs the server in a container with testcontainers.
+	BackendDocker Backend = "docker"
+	// BackendLocal runs the locally installed initdb and postgres binaries.
+	BackendLocal Backend = "local"
+)
+
+type ServerConfig struct {
+	Backend *Backend
+	// Image is the Docker image used by BackendDocker.
+	Image *string
+	// BinDir is the directory of the initdb and postgres binaries used by
+	// BackendLocal. The binaries are looked up in PATH and in the output
+	// of pg_config --bindir if it is nil.
+	BinDir   *string
+	User     *string
+	Password *string
+	// Migrations are applied to the template database that the databases
+	// returned by NewDatabase are cloned from. DSN is ignored.
+	Migrations *pkgsql.MigratorConfig
+	// StartupTimeout is the maximum time to wait for the server to accept connections.
+	StartupTimeout *time.Duration
+}
+
+func (c *ServerConfig) Validate() error {
+	if c.Backend == nil {
+		c.Backend = pkgptr.Ptr(BackendAuto)
+	}
+
+	if c.Image == nil {
+		c.Image = pkgptr.Ptr(DefaultImage)
+	}
+
+	if c.User == nil {
+		c.User = pkgptr.Ptr(DefaultUser)
+	}
+
+	if c.Password == nil {
+		c.Password = pkgptr.Ptr(DefaultPassword)
+	}
+
+	if c.StartupTimeout == nil {
+		c.StartupTimeout = pkgptr.Ptr(DefaultStartupTimeout)
+	}
+
+	switch *c.Backend {
+	case BackendAuto, BackendDocker, BackendLocal:
+	default:
+		return fmt.Errorf("unknown backend %s", *c.Backend)
+	}
+
+	return nil
+}
+
+// Server is a running Postgres server with a migrated template database.
+type Server struct {
+	dsn       string
+	admin     *pkgpostgres.SQLConn
+	stop      func(ctx context.Context) error
+	counter   atomic.Int64
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// StartServer starts the server, creates the template database and applies
+// the migrations to it. Close must be called to stop the server.
+func StartServer(ctx context.Context, cfg ServerConfig) (*Server, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid server config: %w", err)
+	}
+
+	dsn, stop, err := startBackend(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &Server{
+		dsn:  dsn,
+		stop: stop,
+	}
+
+	err = srv.init(ctx, cfg)
+	if err != nil {
+		return nil, errors.Join(err, srv.Close(context.Background()))
+	}
+
+	return srv, nil
+}
+
+func startBackend(ctx context.Context, cfg ServerConfig) (string, func(ctx context.Context) error, error) {
+	switch *cfg.Backend {
+	case BackendDocker:
+		return startDocker(ctx, cfg)
+	case BackendLocal:
+		return startLocal(ctx, cfg)
+	}
+
+	dsn, stop, dockerErr := startDocker(ctx, cfg)
+	if dockerErr == nil {
+		return dsn, stop, nil
+	}
+
+	dsn, stop, localErr := startLocal(ctx, cfg)
+	if localErr != nil {
+		return "", nil, errors.Join(dockerErr, localErr)
+	}
+
+	return dsn, stop, nil
+}
+
+func (s *Server) init(ctx context.Context, cfg ServerConfig) error {
+	ctx, cancel := context.WithTimeout(ctx, *cfg.StartupTimeout)
+	defer cancel()
+
+	// The server may still be starting up. The attempts are limited, but
+	// there are more of them than fit into the startup timeout, so the
+	// timeout of the context stops the retries.
+	admin, err := pkgpostgres.NewSQLConn(ctx, pkgpostgres.SQLConnConfig{
+		DSN:                  s.dsn,
+		RetryConnectAttempts: pkgptr.Ptr(uint(*cfg.StartupTimeout/startupRetryDelay) + 1),
+		RetryConnectDelay:    pkgptr.Ptr(startupRetryDelay),
+		RetryConnectMaxDelay: pkgptr.Ptr(startupRetryDelay),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	s.admin = admin
+
+	_, err = admin.ExecContext(ctx, "CREATE DATABASE "+pgx.Identifier{templateDatabase}.Sanitize())
+	if err != nil {
+		return fmt.Errorf("failed to create template database: %w", err)
+	}
+
+	if cfg.Migrations == nil {
+		return nil
+	}
+
+	migratorCfg := *cfg.Migrations
+	migratorCfg.DSN = s.TemplateDSN()
+
+	migrator, err := pkgsql.NewMigrator(migratorCfg)
+	if err != nil {
+		return fmt.Errorf("failed to create migrator: %w", err)
+	}
+
+	err = migrator.MigrateUp()
+	if errors.Is(err, pkgsql.ErrNoChange) {
+		err = nil
+	}
+
+	return errors.Join(err, migrator.Close())
+}
+
+// DSN returns the connection string of the default database.
+func (s *Server) DSN() string {
+	return s.dsn
+}
+
+// TemplateDSN returns the connection string of the migrated template database.
+// Nothing should stay connected to it while NewDatabase or CreateDatabase is called.
+func (s *Server) TemplateDSN() string {
+	return databaseDSN(s.dsn, templateDatabase)
+}
+
+// CreateDatabase creates a new database cloned from the template database
+// and returns its connection string.
+func (s *Server) CreateDatabase(ctx context.Context) (string, error) {
+	name := fmt.Sprintf("pkgtest_%d", s.counter.Add(1))
+
+	_, err := s.admin.ExecContext(ctx, fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s",
+		pgx.Identifier{name}.Sanitize(), pgx.Identifier{templateDatabase}.Sanitize()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+
+	return databaseDSN(s.dsn, name), nil
+}
+
+// DropDatabase drops the database created by CreateDatabase, terminating
+// the connections to it.
+func (s *Server) DropDatabase(ctx context.Context, dsn string) error {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return err
+	}
+
+	name := u.Path[1:]
+
+	_, err = s.admin.ExecContext(ctx, "DROP DATABASE IF EXISTS "+pgx.Identifier{name}.Sanitize()+" WITH (FORCE)")
+	if err != nil {
+		return fmt.Errorf("failed to drop database %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Close stops the server and removes its data.
+func (s *Server) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		if s.admin != nil {
+			s.closeErr = s.admin.Close()
+		}
+
+		s.closeErr = errors.Join(s.closeErr, s.stop(ctx))
+	})
+
+	return s.closeErr
+}
+
+// Start starts the server with StartServer and stops it when the test
+// completes. To share a server between all the tests of a package, call
+// StartServer from TestMain instead.
+func Start(tb testing.TB, cfg ServerConfig) *Server {
+	tb.Helper()
+
+	srv, err := StartServer(context.Background(), cfg)
+	if err != nil {
+		tb.Fatalf("failed to start postgres: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		err := srv.Close(context.Background())
+		if err != nil {
+			tb.Errorf("failed to stop postgres: %v", err)
+		}
+	})
+
+	return srv
+}
+
+// NewDatabase creates a database cloned from the template database and
+// drops it when the test completes. It returns the connection string.
+func (s *Server) NewDatabase(tb testing.TB) string {
+	tb.Helper()
+
+	dsn, err := s.CreateDatabase(context.Background())
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() {
+		err := s.DropDatabase(context.Background(), dsn)
+		if err != nil {
+			tb.Errorf("failed to drop test database: %v", err)
+		}
+	})
+
+	return dsn
+}
+
+// NewSQLConn creates a database with NewDatabase and connects to it.
+// The connection is closed when the test completes.
+func (s *Server) NewSQLConn(tb testing.TB) *pkgpostgres.SQLConn {
+	tb.Helper()
+
+	conn, err := pkgpostgres.NewSQLConn(context.Background(), pkgpostgres.SQLConnConfig{
+		DSN: s.NewDatabase(tb),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	// Registered after NewDatabase, so it runs before the database is dropped.
+	tb.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return conn
+}
+
+// databaseDSN returns the URL connection string with the database replaced.
+func databaseDSN(dsn, database string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return dsn
+	}
+
+	u.Path = "/" + database
+
+	return u.String()
+}
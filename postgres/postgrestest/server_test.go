@@ -0,0 +1,82 @@
+package pkgpostgrestest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	pkgpostgrestest "github.com/amanbolat/pkg/postgres/postgrestest"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	_, err := pkgpostgrestest.StartServer(context.Background(), pkgpostgrestest.ServerConfig{
+		Backend: pkgptr.Ptr(pkgpostgrestest.Backend("vm")),
+	})
+	require.ErrorContains(t, err, "unknown backend vm")
+
+	if testing.Short() {
+		t.Skip("skipping postgres server test in short mode")
+	}
+
+	srv, err := pkgpostgrestest.StartServer(context.Background(), pkgpostgrestest.ServerConfig{
+		Migrations: &pkgsql.MigratorConfig{
+			MigrationsFs: fstest.MapFS{
+				"20231031000000_users.up.sql":   {Data: []byte(`CREATE TABLE users (id bigint PRIMARY KEY);`)},
+				"20231031000000_users.down.sql": {Data: []byte(`DROP TABLE users;`)},
+			},
+			MigrationsDir: ".",
+			Format:        pkgsql.MigrationFormatGomigrate,
+		},
+	})
+	if err != nil {
+		t.Skipf("postgres is not available: %v", err)
+	}
+	t.Cleanup(func() {
+		assert.NoError(t, srv.Close(context.Background()))
+	})
+
+	ctx := context.Background()
+
+	// Every database is cloned from the migrated template and is isolated.
+	first := srv.NewSQLConn(t)
+	second := srv.NewSQLConn(t)
+
+	_, err = first.ExecContext(ctx, `INSERT INTO users (id) VALUES (1)`)
+	require.NoError(t, err)
+
+	var count int
+	err = second.QueryRowContext(ctx, `SELECT count(*) FROM users`).Scan(&count)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
+
+// TestServer_StartupTimeout runs the local backend with fake binaries of
+// a server that never accepts connections, so it doesn't need Postgres.
+func TestServer_StartupTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binaries are shell scripts")
+	}
+
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "initdb"), []byte("#!/bin/sh\nexit 0\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "postgres"), []byte("#!/bin/sh\nexec sleep 30\n"), 0o755))
+
+	start := time.Now()
+	_, err := pkgpostgrestest.StartServer(context.Background(), pkgpostgrestest.ServerConfig{
+		Backend:        pkgptr.Ptr(pkgpostgrestest.BackendLocal),
+		BinDir:         pkgptr.Ptr(binDir),
+		StartupTimeout: pkgptr.Ptr(time.Second),
+	})
+	require.ErrorContains(t, err, "failed to connect to postgres")
+	// The connection is retried until the startup timeout.
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+	assert.Less(t, time.Since(start), time.Second*10)
+}
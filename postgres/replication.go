@@ -0,0 +1,559 @@
+package pkgpostgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const DefaultReplicationStatusInterval = time.Second * 10
+
+// replicationNameRegex matches the names allowed for the replication slots.
+var replicationNameRegex = regexp.MustCompile(`^[a-z0-9_]{1,63}$`)
+
+// LSN is a position in the write-ahead log.
+type LSN uint64
+
+// ParseLSN parses the LSN in the X/X format, e.g. 16/B374D848.
+func ParseLSN(s string) (LSN, error) {
+	var hi, lo uint32
+	_, err := fmt.Sscanf(s, "%X/%X", &hi, &lo)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lsn %s: %w", s, err)
+	}
+
+	return LSN(uint64(hi)<<32 | uint64(lo)), nil
+}
+
+func (l LSN) String() string {
+	return fmt.Sprintf("%X/%X", uint32(l>>32), uint32(l))
+}
+
+// ChangeKind is the kind of the row change.
+type ChangeKind string
+
+const (
+	ChangeInsert   ChangeKind = "insert"
+	ChangeUpdate   ChangeKind = "update"
+	ChangeDelete   ChangeKind = "delete"
+	ChangeTruncate ChangeKind = "truncate"
+)
+
+// ChangeEvent is a change of a row committed to the database.
+type ChangeEvent struct {
+	Kind   ChangeKind
+	Schema string
+	Table  string
+	// New is the new row of insert and update. Numeric values are
+	// pkgdecimal.Decimal, the values of unknown types, e.g. enums, are strings.
+	New map[string]any
+	// Old is the old row of update and delete. It has only the replica
+	// identity columns, e.g. the primary key, unless the table has
+	// REPLICA IDENTITY FULL. It is nil for the updates that don't change
+	// the replica identity.
+	Old map[string]any
+	// Unchanged are the TOASTed columns of update that are missing in New,
+	// because they weren't changed.
+	Unchanged []string
+	// XID is the ID of the transaction.
+	XID uint32
+	// CommitLSN is the LSN of the commit of the transaction.
+	CommitLSN  LSN
+	CommitTime time.Time
+}
+
+// ChangeHandler handles the change events in the commit order.
+type ChangeHandler func(ctx context.Context, event ChangeEvent) error
+
+type ChangeConsumerConfig struct {
+	// Conn provides the DSN, the retry settings and the PasswordProvider
+	// used to (re)connect. The user must have the REPLICATION attribute.
+	Conn SQLConnConfig
+	// SlotName is the logical replication slot. It is created if it doesn't
+	// exist. The slot keeps the position of the consumer, so it resumes
+	// after restarts from the last acknowledged transaction.
+	SlotName string
+	// PublicationName is the publication that defines the replicated
+	// tables. It is created if it doesn't exist.
+	PublicationName string
+	// Tables are the tables of the created publication. All the tables are
+	// published if it is empty.
+	Tables []string
+	// Handler is called for every change.
+	Handler ChangeHandler
+	// StatusInterval is the time between the reports of the acknowledged
+	// position to the server.
+	StatusInterval *time.Duration
+	// OnDisconnect is called when the connection is lost, before reconnecting.
+	OnDisconnect func(err error)
+}
+
+func (c *ChangeConsumerConfig) Validate() error {
+	err := c.Conn.Validate()
+	if err != nil {
+		return err
+	}
+
+	if !replicationNameRegex.MatchString(c.SlotName) {
+		return fmt.Errorf("slot name %q must consist of lower case letters, numbers and underscores", c.SlotName)
+	}
+
+	if !replicationNameRegex.MatchString(c.PublicationName) {
+		return fmt.Errorf("publication name %q must consist of lower case letters, numbers and underscores", c.PublicationName)
+	}
+
+	if c.Handler == nil {
+		return fmt.Errorf("handler is required")
+	}
+
+	if c.StatusInterval == nil {
+		c.StatusInterval = pkgptr.Ptr(DefaultReplicationStatusInterval)
+	}
+
+	if *c.StatusInterval <= 0 {
+		return fmt.Errorf("status interval must be positive")
+	}
+
+	return nil
+}
+
+// handlerError is the error of ChangeHandler that stops the consumer
+// instead of reconnecting.
+type handlerError struct {
+	err error
+}
+
+func (e *handlerError) Error() string {
+	return fmt.Sprintf("change handler failed: %v", e.err)
+}
+
+func (e *handlerError) Unwrap() error {
+	return e.err
+}
+
+// ChangeConsumer captures the row changes with logical replication using
+// the pgoutput plugin. A transaction is acknowledged after the handler
+// returns for all of its changes, so the changes are delivered at least
+// once: the changes of the transaction that wasn't acknowledged are
+// delivered again after a restart.
+//
+// Example:
+//
+//	consumer, err := pkgpostgres.NewChangeConsumer(pkgpostgres.ChangeConsumerConfig{
+//	    Conn:            pkgpostgres.SQLConnConfig{DSN: dsn},
+//	    SlotName:        "search_indexer",
+//	    PublicationName: "search_indexer",
+//	    Tables:          []string{"products"},
+//	    Handler: func(ctx context.Context, e pkgpostgres.ChangeEvent) error {
+//	        return index.Apply(ctx, e)
+//	    },
+//	})
+//	err = consumer.Run(ctx)
+type ChangeConsumer struct {
+	cfg     ChangeConsumerConfig
+	pgxCfg  *pgx.ConnConfig
+	typeMap *pgtype.Map
+	acked   atomic.Uint64
+}
+
+// NewChangeConsumer creates a new ChangeConsumer. Call Run to start it.
+func NewChangeConsumer(cfg ChangeConsumerConfig) (*ChangeConsumer, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ChangeConsumer config: %w", err)
+	}
+
+	pgxCfg, err := pgx.ParseConfig(cfg.Conn.DSN)
+	if err != nil {
+		return nil, err
+	}
+	configureConnConfig(pgxCfg, cfg.Conn.Session)
+	pgxCfg.RuntimeParams["replication"] = "database"
+
+	return &ChangeConsumer{
+		cfg:     cfg,
+		pgxCfg:  pgxCfg,
+		typeMap: pgtype.NewMap(),
+	}, nil
+}
+
+// AckedLSN returns the position up to which the changes were handled.
+func (c *ChangeConsumer) AckedLSN() LSN {
+	return LSN(c.acked.Load())
+}
+
+// Run creates the publication and the slot if they don't exist and
+// delivers the changes until the context is canceled, an error other
+// than the lost connection occurs or the connection can't be
+// reestablished within the configured retry attempts.
+func (c *ChangeConsumer) Run(ctx context.Context) error {
+	for {
+		conn, err := c.connect(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect change consumer: %w", err)
+		}
+
+		err = c.setup(ctx, conn)
+		if err == nil {
+			err = c.stream(ctx, conn)
+		}
+		_ = conn.Close(context.Background())
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// Only the lost connections are reestablished, the other errors,
+		// e.g. missing privileges or the slot used by another consumer,
+		// are returned.
+		var hErr *handlerError
+		if errors.As(err, &hErr) || !IsConnectionError(err) {
+			return err
+		}
+
+		if c.cfg.OnDisconnect != nil {
+			c.cfg.OnDisconnect(err)
+		}
+	}
+}
+
+func (c *ChangeConsumer) connect(ctx context.Context) (*pgconn.PgConn, error) {
+	var conn *pgconn.PgConn
+	err := retryConnect(ctx, retryConnectConfig{
+		attempts:  *c.cfg.Conn.RetryConnectAttempts,
+		delay:     *c.cfg.Conn.RetryConnectDelay,
+		maxDelay:  *c.cfg.Conn.RetryConnectMaxDelay,
+		maxJitter: *c.cfg.Conn.RetryConnectMaxJitter,
+		onRetry:   c.cfg.Conn.OnRetry,
+	}, func() error {
+		connCfg := c.pgxCfg.Copy()
+		connErr := beforeConnect(c.cfg.Conn.PasswordProvider)(ctx, connCfg)
+		if connErr != nil {
+			return connErr
+		}
+
+		conn, connErr = pgconn.ConnectConfig(ctx, &connCfg.Config)
+
+		return connErr
+	})
+
+	return conn, err
+}
+
+// setup creates the publication and the slot if they don't exist.
+// The names are validated, so they can be used in the queries as is.
+func (c *ChangeConsumer) setup(ctx context.Context, conn *pgconn.PgConn) error {
+	publication, err := replicationQueryValue(ctx, conn,
+		fmt.Sprintf("SELECT 1 FROM pg_publication WHERE pubname = '%s'", c.cfg.PublicationName), 0)
+	if err != nil {
+		return fmt.Errorf("failed to check publication: %w", err)
+	}
+
+	if publication == nil {
+		target := "ALL TABLES"
+		if len(c.cfg.Tables) > 0 {
+			tables := make([]string, 0, len(c.cfg.Tables))
+			for _, t := range c.cfg.Tables {
+				tables = append(tables, parseIdentifier(t).Sanitize())
+			}
+			target = "TABLE " + strings.Join(tables, ", ")
+		}
+
+		_, err = conn.Exec(ctx, fmt.Sprintf("CREATE PUBLICATION %s FOR %s", c.cfg.PublicationName, target)).ReadAll()
+		if err != nil {
+			return fmt.Errorf("failed to create publication: %w", err)
+		}
+	}
+
+	// The consumer resumes from the confirmed position of the slot.
+	confirmed, err := replicationQueryValue(ctx, conn,
+		fmt.Sprintf("SELECT confirmed_flush_lsn::text FROM pg_replication_slots WHERE slot_name = '%s'", c.cfg.SlotName), 0)
+	if err != nil {
+		return fmt.Errorf("failed to check replication slot: %w", err)
+	}
+
+	if confirmed == nil {
+		// The second column of the result is the consistent point of the slot.
+		confirmed, err = replicationQueryValue(ctx, conn,
+			fmt.Sprintf("CREATE_REPLICATION_SLOT %s LOGICAL pgoutput NOEXPORT_SNAPSHOT", c.cfg.SlotName), 1)
+		if err != nil {
+			return fmt.Errorf("failed to create replication slot: %w", err)
+		}
+	}
+
+	if len(confirmed) > 0 {
+		lsn, err := ParseLSN(string(confirmed))
+		if err != nil {
+			return err
+		}
+
+		c.ack(lsn)
+	}
+
+	return nil
+}
+
+// replicationQueryValue returns the column of the first row, nil if
+// there are no rows.
+func replicationQueryValue(ctx context.Context, conn *pgconn.PgConn, query string, column int) ([]byte, error) {
+	results, err := conn.Exec(ctx, query).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) == 0 || len(results[0].Rows) == 0 || len(results[0].Rows[0]) <= column {
+		return nil, nil
+	}
+
+	return results[0].Rows[0][column], nil
+}
+
+// stream starts the replication from the position acknowledged in the
+// slot and handles the messages until an error occurs.
+func (c *ChangeConsumer) stream(ctx context.Context, conn *pgconn.PgConn) error {
+	// 0/0 starts from the confirmed position of the slot.
+	query := fmt.Sprintf("START_REPLICATION SLOT %s LOGICAL 0/0 (proto_version '1', publication_names '%s')",
+		c.cfg.SlotName, c.cfg.PublicationName)
+
+	conn.Frontend().Send(&pgproto3.Query{String: query})
+	err := conn.Frontend().Flush()
+	if err != nil {
+		return fmt.Errorf("failed to start replication: %w", err)
+	}
+
+	err = waitCopyBoth(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("failed to start replication: %w", err)
+	}
+
+	s := &replicationStream{
+		consumer:  c,
+		conn:      conn,
+		relations: make(map[uint32]relation),
+	}
+
+	return s.run(ctx)
+}
+
+func waitCopyBoth(ctx context.Context, conn *pgconn.PgConn) error {
+	for {
+		msg, err := conn.ReceiveMessage(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.CopyBothResponse:
+			return nil
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		}
+	}
+}
+
+// replicationStream is the state of a single replication connection.
+type replicationStream struct {
+	consumer   *ChangeConsumer
+	conn       *pgconn.PgConn
+	relations  map[uint32]relation
+	begin      *pgoutputBeginMessage
+	nextStatus time.Time
+}
+
+func (s *replicationStream) run(ctx context.Context) error {
+	for {
+		if !time.Now().Before(s.nextStatus) {
+			err := s.sendStatus()
+			if err != nil {
+				return err
+			}
+		}
+
+		recvCtx, cancel := context.WithDeadline(ctx, s.nextStatus)
+		msg, err := s.conn.ReceiveMessage(recvCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			// Best effort to save the position before the connection is closed.
+			_ = s.sendStatus()
+
+			return ctx.Err()
+		}
+
+		if pgconn.Timeout(err) {
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to receive replication message: %w", err)
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.CopyData:
+			err = s.handleCopyData(ctx, msg.Data)
+			if err != nil {
+				return err
+			}
+		case *pgproto3.ErrorResponse:
+			return pgconn.ErrorResponseToPgError(msg)
+		case *pgproto3.CopyDone:
+			return fmt.Errorf("replication stream ended by server")
+		}
+	}
+}
+
+func (s *replicationStream) handleCopyData(ctx context.Context, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	switch data[0] {
+	case primaryKeepaliveMessageByteID:
+		ka, err := parsePrimaryKeepalive(data[1:])
+		if err != nil {
+			return err
+		}
+
+		// Outside of a transaction everything before walEnd is handled,
+		// so the position can advance even if no tables are changed.
+		if s.begin == nil {
+			s.consumer.ack(ka.walEnd)
+		}
+
+		if ka.replyRequested {
+			s.nextStatus = time.Time{}
+		}
+	case xLogDataByteID:
+		xld, err := parseXLogData(data[1:])
+		if err != nil {
+			return err
+		}
+
+		return s.handleWAL(ctx, xld)
+	}
+
+	return nil
+}
+
+func (s *replicationStream) handleWAL(ctx context.Context, xld xLogData) error {
+	msg, err := parsePgoutputMessage(xld.walData)
+	if err != nil {
+		return err
+	}
+
+	switch msg := msg.(type) {
+	case relation:
+		s.relations[msg.id] = msg
+	case pgoutputBeginMessage:
+		s.begin = &msg
+	case pgoutputCommitMessage:
+		s.begin = nil
+		s.consumer.ack(msg.endLSN)
+	case pgoutputRowMessage:
+		event, err := s.rowEvent(msg)
+		if err != nil {
+			return err
+		}
+
+		return s.handle(ctx, event)
+	case pgoutputTruncateMessage:
+		for _, id := range msg.relationIDs {
+			rel, ok := s.relations[id]
+			if !ok {
+				return fmt.Errorf("unknown relation %d", id)
+			}
+
+			event := s.event(ChangeTruncate, rel)
+			err = s.handle(ctx, event)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *replicationStream) rowEvent(msg pgoutputRowMessage) (ChangeEvent, error) {
+	rel, ok := s.relations[msg.relationID]
+	if !ok {
+		return ChangeEvent{}, fmt.Errorf("unknown relation %d", msg.relationID)
+	}
+
+	event := s.event(msg.kind, rel)
+
+	var err error
+	event.New, event.Unchanged, err = decodeTuple(s.consumer.typeMap, rel, msg.newTuple)
+	if err != nil {
+		return ChangeEvent{}, err
+	}
+
+	event.Old, _, err = decodeTuple(s.consumer.typeMap, rel, msg.oldTuple)
+	if err != nil {
+		return ChangeEvent{}, err
+	}
+
+	return event, nil
+}
+
+func (s *replicationStream) event(kind ChangeKind, rel relation) ChangeEvent {
+	event := ChangeEvent{
+		Kind:   kind,
+		Schema: rel.namespace,
+		Table:  rel.name,
+	}
+
+	if s.begin != nil {
+		event.XID = s.begin.xid
+		event.CommitLSN = s.begin.finalLSN
+		event.CommitTime = s.begin.commitTime
+	}
+
+	return event
+}
+
+func (s *replicationStream) handle(ctx context.Context, event ChangeEvent) error {
+	err := s.consumer.cfg.Handler(ctx, event)
+	if err != nil {
+		return &handlerError{err: err}
+	}
+
+	return nil
+}
+
+func (c *ChangeConsumer) ack(lsn LSN) {
+	if lsn > c.AckedLSN() {
+		c.acked.Store(uint64(lsn))
+	}
+}
+
+// sendStatus reports the acknowledged position, so the server can
+// recycle the write-ahead log and the consumer resumes from it.
+func (s *replicationStream) sendStatus() error {
+	s.nextStatus = time.Now().Add(*s.consumer.cfg.StatusInterval)
+
+	// The position is unknown until the slot is read, and reporting
+	// zero could move the confirmed position of the slot back.
+	acked := s.consumer.AckedLSN()
+	if acked == 0 {
+		return nil
+	}
+
+	s.conn.Frontend().Send(&pgproto3.CopyData{Data: standbyStatusUpdate(acked, time.Now())})
+	err := s.conn.Frontend().Flush()
+	if err != nil {
+		return fmt.Errorf("failed to send standby status: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,393 @@
+package pkgpostgres
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func appendString(b []byte, s string) []byte {
+	return append(append(b, s...), 0)
+}
+
+func appendTuple(b []byte, columns ...tupleColumn) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(columns)))
+	for _, c := range columns {
+		b = append(b, c.kind)
+		if c.kind == 't' || c.kind == 'b' {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(c.data)))
+			b = append(b, c.data...)
+		}
+	}
+
+	return b
+}
+
+func textColumn(s string) tupleColumn {
+	return tupleColumn{kind: 't', data: []byte(s)}
+}
+
+func beginMessage(finalLSN LSN, commitTime time.Time, xid uint32) []byte {
+	b := []byte{pgoutputBegin}
+	b = binary.BigEndian.AppendUint64(b, uint64(finalLSN))
+	b = binary.BigEndian.AppendUint64(b, uint64(pgMicros(commitTime)))
+
+	return binary.BigEndian.AppendUint32(b, xid)
+}
+
+func commitMessage(commitLSN, endLSN LSN) []byte {
+	b := []byte{pgoutputCommit, 0}
+	b = binary.BigEndian.AppendUint64(b, uint64(commitLSN))
+	b = binary.BigEndian.AppendUint64(b, uint64(endLSN))
+
+	return binary.BigEndian.AppendUint64(b, 0)
+}
+
+func relationMessage(rel relation) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{pgoutputRelation}, rel.id)
+	b = appendString(b, rel.namespace)
+	b = appendString(b, rel.name)
+	b = append(b, 'd')
+	b = binary.BigEndian.AppendUint16(b, uint16(len(rel.columns)))
+	for _, col := range rel.columns {
+		b = append(b, 0)
+		b = appendString(b, col.name)
+		b = binary.BigEndian.AppendUint32(b, col.typeOID)
+		b = binary.BigEndian.AppendUint32(b, 0xFFFFFFFF)
+	}
+
+	return b
+}
+
+func insertMessage(relationID uint32, columns ...tupleColumn) []byte {
+	b := binary.BigEndian.AppendUint32([]byte{pgoutputInsert}, relationID)
+
+	return appendTuple(append(b, 'N'), columns...)
+}
+
+func xLogDataMessage(walStart LSN, walData []byte) []byte {
+	b := []byte{xLogDataByteID}
+	b = binary.BigEndian.AppendUint64(b, uint64(walStart))
+	b = binary.BigEndian.AppendUint64(b, uint64(walStart))
+	b = binary.BigEndian.AppendUint64(b, 0)
+
+	return append(b, walData...)
+}
+
+func keepaliveMessage(walEnd LSN, replyRequested bool) []byte {
+	b := []byte{primaryKeepaliveMessageByteID}
+	b = binary.BigEndian.AppendUint64(b, uint64(walEnd))
+	b = binary.BigEndian.AppendUint64(b, 0)
+	if replyRequested {
+		return append(b, 1)
+	}
+
+	return append(b, 0)
+}
+
+func TestParsePgoutputMessage(t *testing.T) {
+	t.Parallel()
+
+	commitTime := time.Date(2023, 11, 20, 10, 0, 0, 123456000, time.UTC)
+	rel := relation{
+		id:        16384,
+		namespace: "public",
+		name:      "orders",
+		columns: []relationColumn{
+			{name: "id", typeOID: pgtype.Int8OID},
+			{name: "total", typeOID: pgtype.NumericOID},
+		},
+	}
+	oldTuple := []tupleColumn{textColumn("7"), {kind: 'n'}}
+	newTuple := []tupleColumn{textColumn("7"), textColumn("12.50")}
+
+	update := func(tags ...byte) []byte {
+		b := binary.BigEndian.AppendUint32([]byte{pgoutputUpdate}, rel.id)
+		for _, tag := range tags {
+			b = append(b, tag)
+			if tag == 'N' {
+				b = appendTuple(b, newTuple...)
+			} else {
+				b = appendTuple(b, oldTuple...)
+			}
+		}
+
+		return b
+	}
+
+	deleteMessage := binary.BigEndian.AppendUint32([]byte{pgoutputDelete}, rel.id)
+	deleteMessage = appendTuple(append(deleteMessage, 'K'), oldTuple...)
+
+	truncateMessage := binary.BigEndian.AppendUint32([]byte{pgoutputTruncate}, 2)
+	truncateMessage = append(truncateMessage, 0)
+	truncateMessage = binary.BigEndian.AppendUint32(truncateMessage, 16384)
+	truncateMessage = binary.BigEndian.AppendUint32(truncateMessage, 16390)
+
+	tests := []struct {
+		name string
+		data []byte
+		want any
+		err  bool
+	}{
+		{
+			name: "begin",
+			data: beginMessage(0x16B374D848, commitTime, 731),
+			want: pgoutputBeginMessage{finalLSN: 0x16B374D848, commitTime: commitTime, xid: 731},
+		},
+		{
+			name: "commit",
+			data: commitMessage(0x16B374D848, 0x16B374D878),
+			want: pgoutputCommitMessage{commitLSN: 0x16B374D848, endLSN: 0x16B374D878},
+		},
+		{
+			name: "relation",
+			data: relationMessage(rel),
+			want: rel,
+		},
+		{
+			name: "insert",
+			data: insertMessage(rel.id, newTuple...),
+			want: pgoutputRowMessage{kind: ChangeInsert, relationID: rel.id, newTuple: newTuple},
+		},
+		{
+			name: "update",
+			data: update('N'),
+			want: pgoutputRowMessage{kind: ChangeUpdate, relationID: rel.id, newTuple: newTuple},
+		},
+		{
+			name: "update of the key",
+			data: update('K', 'N'),
+			want: pgoutputRowMessage{kind: ChangeUpdate, relationID: rel.id, oldTuple: oldTuple, newTuple: newTuple},
+		},
+		{
+			name: "update with the old row",
+			data: update('O', 'N'),
+			want: pgoutputRowMessage{kind: ChangeUpdate, relationID: rel.id, oldTuple: oldTuple, newTuple: newTuple},
+		},
+		{
+			name: "delete",
+			data: deleteMessage,
+			want: pgoutputRowMessage{kind: ChangeDelete, relationID: rel.id, oldTuple: oldTuple},
+		},
+		{
+			name: "truncate",
+			data: truncateMessage,
+			want: pgoutputTruncateMessage{relationIDs: []uint32{16384, 16390}},
+		},
+		{
+			name: "origin is skipped",
+			data: appendString(binary.BigEndian.AppendUint64([]byte{pgoutputOrigin}, 0), "upstream"),
+		},
+		{
+			name: "empty",
+			err:  true,
+		},
+		{
+			name: "too short",
+			data: beginMessage(0x16B374D848, commitTime, 731)[:10],
+			err:  true,
+		},
+		{
+			name: "unknown tuple column kind",
+			data: append(binary.BigEndian.AppendUint32([]byte{pgoutputInsert}, rel.id), 'N', 0, 1, 'x'),
+			err:  true,
+		},
+		{
+			name: "unknown message",
+			data: []byte{'Z'},
+			err:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		msg, err := parsePgoutputMessage(tt.data)
+		if tt.err {
+			assert.Error(t, err, tt.name)
+
+			continue
+		}
+
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.want, msg, tt.name)
+	}
+}
+
+func TestParseXLogData(t *testing.T) {
+	t.Parallel()
+
+	data := xLogDataMessage(0x16B374D848, []byte{pgoutputBegin})
+
+	xld, err := parseXLogData(data[1:])
+	require.NoError(t, err)
+	assert.Equal(t, xLogData{walStart: 0x16B374D848, walData: []byte{pgoutputBegin}}, xld)
+
+	_, err = parseXLogData(data[1:20])
+	assert.ErrorIs(t, err, errShortMessage)
+}
+
+func TestParsePrimaryKeepalive(t *testing.T) {
+	t.Parallel()
+
+	ka, err := parsePrimaryKeepalive(keepaliveMessage(0x16B374D848, true)[1:])
+	require.NoError(t, err)
+	assert.Equal(t, primaryKeepalive{walEnd: 0x16B374D848, replyRequested: true}, ka)
+
+	ka, err = parsePrimaryKeepalive(keepaliveMessage(0x16B374D848, false)[1:])
+	require.NoError(t, err)
+	assert.False(t, ka.replyRequested)
+
+	_, err = parsePrimaryKeepalive(keepaliveMessage(0x16B374D848, true)[1:16])
+	assert.ErrorIs(t, err, errShortMessage)
+}
+
+func TestStandbyStatusUpdate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC)
+	b := standbyStatusUpdate(0x16B374D848, now)
+
+	require.Len(t, b, 34)
+	assert.Equal(t, byte(standbyStatusUpdateByteID), b[0])
+	for i := 0; i < 3; i++ {
+		// The written, flushed and applied positions.
+		assert.Equal(t, uint64(0x16B374D848), binary.BigEndian.Uint64(b[1+i*8:]))
+	}
+	assert.Equal(t, now, pgTime(int64(binary.BigEndian.Uint64(b[25:]))))
+	assert.Equal(t, byte(0), b[33], "reply requested")
+}
+
+func TestDecodeTuple(t *testing.T) {
+	t.Parallel()
+
+	rel := relation{
+		namespace: "public",
+		name:      "orders",
+		columns: []relationColumn{
+			{name: "id", typeOID: pgtype.Int8OID},
+			{name: "total", typeOID: pgtype.NumericOID},
+			{name: "note", typeOID: pgtype.TextOID},
+			{name: "status", typeOID: 99999},
+			{name: "payload", typeOID: pgtype.JSONBOID},
+		},
+	}
+	m := pgtype.NewMap()
+
+	values, unchanged, err := decodeTuple(m, rel, []tupleColumn{
+		textColumn("7"),
+		textColumn("12.50"),
+		{kind: 'n'},
+		textColumn("shipped"),
+		{kind: 'u'},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"payload"}, unchanged)
+	require.Len(t, values, 4)
+	assert.Equal(t, int64(7), values["id"])
+	assert.Equal(t, "12.50", values["total"].(pkgdecimal.Decimal).String())
+	assert.Contains(t, values, "note")
+	assert.Nil(t, values["note"])
+	assert.Equal(t, "shipped", values["status"])
+	assert.NotContains(t, values, "payload")
+
+	values, unchanged, err = decodeTuple(m, rel, nil)
+	require.NoError(t, err)
+	assert.Nil(t, values)
+	assert.Nil(t, unchanged)
+
+	_, _, err = decodeTuple(m, rel, []tupleColumn{textColumn("7")})
+	assert.Error(t, err)
+
+	_, _, err = decodeTuple(m, rel, []tupleColumn{textColumn("7"), textColumn("twelve"), {kind: 'n'}, {kind: 'n'}, {kind: 'n'}})
+	assert.ErrorContains(t, err, "failed to decode column total")
+}
+
+// TestReplicationStream_Ack checks that a transaction is acknowledged on
+// commit and the keepalive position only outside of a transaction, so a
+// restart never skips the changes the handler hasn't handled.
+func TestReplicationStream_Ack(t *testing.T) {
+	t.Parallel()
+
+	errHandler := errors.New("handler failed")
+
+	var events []ChangeEvent
+	consumer, err := NewChangeConsumer(ChangeConsumerConfig{
+		Conn:            SQLConnConfig{DSN: "postgres://postgres@localhost:5432/postgres"},
+		SlotName:        "search_indexer",
+		PublicationName: "search_indexer",
+		Handler: func(_ context.Context, event ChangeEvent) error {
+			if event.New["id"] == "fail" {
+				return errHandler
+			}
+			events = append(events, event)
+
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	s := &replicationStream{consumer: consumer, relations: make(map[uint32]relation)}
+	ctx := context.Background()
+	commitTime := time.Date(2023, 11, 20, 10, 0, 0, 0, time.UTC)
+
+	handle := func(data []byte) error {
+		t.Helper()
+
+		return s.handleCopyData(ctx, data)
+	}
+	wal := func(msg []byte) []byte {
+		return xLogDataMessage(0, msg)
+	}
+
+	// The keepalive is acknowledged outside of a transaction.
+	require.NoError(t, handle(keepaliveMessage(0x100, false)))
+	assert.Equal(t, LSN(0x100), consumer.AckedLSN())
+
+	require.NoError(t, handle(wal(relationMessage(relation{
+		id:        1,
+		namespace: "public",
+		name:      "orders",
+		columns:   []relationColumn{{name: "id", typeOID: pgtype.TextOID}},
+	}))))
+	require.NoError(t, handle(wal(beginMessage(0x200, commitTime, 731))))
+	require.NoError(t, handle(wal(insertMessage(1, textColumn("a")))))
+
+	// Neither the handled changes nor the keepalive are acknowledged
+	// until the transaction is committed.
+	assert.Equal(t, LSN(0x100), consumer.AckedLSN())
+	require.NoError(t, handle(keepaliveMessage(0x180, true)))
+	assert.Equal(t, LSN(0x100), consumer.AckedLSN())
+	assert.True(t, s.nextStatus.IsZero(), "the reply is requested")
+
+	require.NoError(t, handle(wal(commitMessage(0x200, 0x210))))
+	assert.Equal(t, LSN(0x210), consumer.AckedLSN())
+
+	require.Len(t, events, 1)
+	assert.Equal(t, ChangeEvent{
+		Kind:       ChangeInsert,
+		Schema:     "public",
+		Table:      "orders",
+		New:        map[string]any{"id": "a"},
+		XID:        731,
+		CommitLSN:  0x200,
+		CommitTime: commitTime,
+	}, events[0])
+
+	// The transaction is not acknowledged after the handler error, so
+	// its changes are delivered again.
+	require.NoError(t, handle(wal(beginMessage(0x300, commitTime, 732))))
+	err = handle(wal(insertMessage(1, textColumn("fail"))))
+	var hErr *handlerError
+	require.ErrorAs(t, err, &hErr)
+	assert.ErrorIs(t, err, errHandler)
+	assert.Equal(t, LSN(0x210), consumer.AckedLSN())
+
+	require.NoError(t, handle(keepaliveMessage(0x380, false)))
+	assert.Equal(t, LSN(0x210), consumer.AckedLSN())
+}
@@ -0,0 +1,365 @@
+package pkgpostgres
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// postgresEpoch is the epoch of the timestamps in the replication protocol.
+var postgresEpoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var errShortMessage = errors.New("replication message is too short")
+
+func pgTime(micros int64) time.Time {
+	return postgresEpoch.Add(time.Duration(micros) * time.Microsecond)
+}
+
+func pgMicros(t time.Time) int64 {
+	return t.Sub(postgresEpoch).Microseconds()
+}
+
+// Messages of the streaming replication protocol sent in CopyData.
+const (
+	xLogDataByteID                = 'w'
+	primaryKeepaliveMessageByteID = 'k'
+	standbyStatusUpdateByteID     = 'r'
+)
+
+type xLogData struct {
+	walStart LSN
+	walData  []byte
+}
+
+func parseXLogData(b []byte) (xLogData, error) {
+	if len(b) < 24 {
+		return xLogData{}, errShortMessage
+	}
+
+	return xLogData{
+		walStart: LSN(binary.BigEndian.Uint64(b)),
+		walData:  b[24:],
+	}, nil
+}
+
+type primaryKeepalive struct {
+	walEnd         LSN
+	replyRequested bool
+}
+
+func parsePrimaryKeepalive(b []byte) (primaryKeepalive, error) {
+	if len(b) < 17 {
+		return primaryKeepalive{}, errShortMessage
+	}
+
+	return primaryKeepalive{
+		walEnd:         LSN(binary.BigEndian.Uint64(b)),
+		replyRequested: b[16] != 0,
+	}, nil
+}
+
+// standbyStatusUpdate encodes the message that reports the written,
+// flushed and applied positions to the server.
+func standbyStatusUpdate(lsn LSN, now time.Time) []byte {
+	b := make([]byte, 0, 34)
+	b = append(b, standbyStatusUpdateByteID)
+	b = binary.BigEndian.AppendUint64(b, uint64(lsn))
+	b = binary.BigEndian.AppendUint64(b, uint64(lsn))
+	b = binary.BigEndian.AppendUint64(b, uint64(lsn))
+	b = binary.BigEndian.AppendUint64(b, uint64(pgMicros(now)))
+
+	return append(b, 0)
+}
+
+// Messages of the pgoutput plugin.
+const (
+	pgoutputBegin    = 'B'
+	pgoutputCommit   = 'C'
+	pgoutputOrigin   = 'O'
+	pgoutputRelation = 'R'
+	pgoutputType     = 'Y'
+	pgoutputInsert   = 'I'
+	pgoutputUpdate   = 'U'
+	pgoutputDelete   = 'D'
+	pgoutputTruncate = 'T'
+	pgoutputMessage  = 'M'
+)
+
+type pgoutputBeginMessage struct {
+	finalLSN   LSN
+	commitTime time.Time
+	xid        uint32
+}
+
+type pgoutputCommitMessage struct {
+	commitLSN LSN
+	endLSN    LSN
+}
+
+type relationColumn struct {
+	name    string
+	typeOID uint32
+}
+
+type relation struct {
+	id        uint32
+	namespace string
+	name      string
+	columns   []relationColumn
+}
+
+// tupleColumn is a column value of a row. kind is 'n' for NULL, 'u' for
+// the unchanged TOASTed value and 't' for the value in the text format.
+type tupleColumn struct {
+	kind byte
+	data []byte
+}
+
+type pgoutputRowMessage struct {
+	kind       ChangeKind
+	relationID uint32
+	oldTuple   []tupleColumn
+	newTuple   []tupleColumn
+}
+
+type pgoutputTruncateMessage struct {
+	relationIDs []uint32
+}
+
+// pgoutputReader reads the fields of a pgoutput message.
+type pgoutputReader struct {
+	b   []byte
+	err error
+}
+
+func (r *pgoutputReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	if len(r.b) < n {
+		r.err = errShortMessage
+
+		return nil
+	}
+
+	b := r.b[:n]
+	r.b = r.b[n:]
+
+	return b
+}
+
+func (r *pgoutputReader) uint8() uint8 {
+	b := r.next(1)
+	if b == nil {
+		return 0
+	}
+
+	return b[0]
+}
+
+func (r *pgoutputReader) uint16() uint16 {
+	b := r.next(2)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint16(b)
+}
+
+func (r *pgoutputReader) uint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *pgoutputReader) uint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *pgoutputReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+
+	for i, c := range r.b {
+		if c == 0 {
+			s := string(r.b[:i])
+			r.b = r.b[i+1:]
+
+			return s
+		}
+	}
+
+	r.err = errShortMessage
+
+	return ""
+}
+
+func (r *pgoutputReader) tuple() []tupleColumn {
+	n := int(r.uint16())
+	columns := make([]tupleColumn, 0, n)
+
+	for i := 0; i < n && r.err == nil; i++ {
+		c := tupleColumn{kind: r.uint8()}
+
+		switch c.kind {
+		case 'n', 'u':
+		case 't', 'b':
+			c.data = r.next(int(r.uint32()))
+		default:
+			r.err = fmt.Errorf("unknown tuple column kind %q", c.kind)
+		}
+		columns = append(columns, c)
+	}
+
+	return columns
+}
+
+// parsePgoutputMessage parses the pgoutput message. The messages that the
+// consumer doesn't need, e.g. Origin and Type, are parsed as nil.
+func parsePgoutputMessage(b []byte) (any, error) {
+	if len(b) == 0 {
+		return nil, errShortMessage
+	}
+
+	r := &pgoutputReader{b: b[1:]}
+
+	var msg any
+	switch b[0] {
+	case pgoutputBegin:
+		msg = pgoutputBeginMessage{
+			finalLSN:   LSN(r.uint64()),
+			commitTime: pgTime(int64(r.uint64())),
+			xid:        r.uint32(),
+		}
+	case pgoutputCommit:
+		r.uint8() // flags
+		msg = pgoutputCommitMessage{
+			commitLSN: LSN(r.uint64()),
+			endLSN:    LSN(r.uint64()),
+		}
+	case pgoutputRelation:
+		rel := relation{
+			id:        r.uint32(),
+			namespace: r.string(),
+			name:      r.string(),
+		}
+		r.uint8() // replica identity
+		n := int(r.uint16())
+		for i := 0; i < n && r.err == nil; i++ {
+			r.uint8() // flags
+			col := relationColumn{name: r.string(), typeOID: r.uint32()}
+			r.uint32() // type modifier
+			rel.columns = append(rel.columns, col)
+		}
+		msg = rel
+	case pgoutputInsert:
+		m := pgoutputRowMessage{kind: ChangeInsert, relationID: r.uint32()}
+		if r.uint8() == 'N' {
+			m.newTuple = r.tuple()
+		}
+		msg = m
+	case pgoutputUpdate:
+		m := pgoutputRowMessage{kind: ChangeUpdate, relationID: r.uint32()}
+		tag := r.uint8()
+		if tag == 'K' || tag == 'O' {
+			m.oldTuple = r.tuple()
+			tag = r.uint8()
+		}
+		if tag == 'N' {
+			m.newTuple = r.tuple()
+		}
+		msg = m
+	case pgoutputDelete:
+		m := pgoutputRowMessage{kind: ChangeDelete, relationID: r.uint32()}
+		if tag := r.uint8(); tag == 'K' || tag == 'O' {
+			m.oldTuple = r.tuple()
+		}
+		msg = m
+	case pgoutputTruncate:
+		n := int(r.uint32())
+		r.uint8() // options
+		m := pgoutputTruncateMessage{}
+		for i := 0; i < n && r.err == nil; i++ {
+			m.relationIDs = append(m.relationIDs, r.uint32())
+		}
+		msg = m
+	case pgoutputOrigin, pgoutputType, pgoutputMessage:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown pgoutput message %q", b[0])
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("failed to parse pgoutput message %q: %w", b[0], r.err)
+	}
+
+	return msg, nil
+}
+
+// decodeTuple decodes the values of the tuple to the Go types. Numeric
+// values are decoded as pkgdecimal.Decimal, the values of the unknown
+// types, e.g. enums, as strings. The unchanged TOASTed columns are
+// omitted from the values and returned separately.
+func decodeTuple(m *pgtype.Map, rel relation, tuple []tupleColumn) (map[string]any, []string, error) {
+	if tuple == nil {
+		return nil, nil, nil
+	}
+
+	if len(tuple) != len(rel.columns) {
+		return nil, nil, fmt.Errorf("tuple has %d columns, relation %s.%s has %d", len(tuple), rel.namespace, rel.name, len(rel.columns))
+	}
+
+	values := make(map[string]any, len(tuple))
+	var unchanged []string
+
+	for i, c := range tuple {
+		col := rel.columns[i]
+
+		switch c.kind {
+		case 'n':
+			values[col.name] = nil
+		case 'u':
+			unchanged = append(unchanged, col.name)
+		default:
+			v, err := decodeColumnValue(m, col.typeOID, c)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to decode column %s: %w", col.name, err)
+			}
+			values[col.name] = v
+		}
+	}
+
+	return values, unchanged, nil
+}
+
+func decodeColumnValue(m *pgtype.Map, oid uint32, c tupleColumn) (any, error) {
+	format := int16(pgtype.TextFormatCode)
+	if c.kind == 'b' {
+		format = pgtype.BinaryFormatCode
+	}
+
+	if oid == pgtype.NumericOID && format == pgtype.TextFormatCode {
+		return pkgdecimal.FromStr(string(c.data))
+	}
+
+	dt, ok := m.TypeForOID(oid)
+	if !ok {
+		return string(c.data), nil
+	}
+
+	return dt.Codec.DecodeValue(m, oid, format, c.data)
+}
@@ -0,0 +1,50 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLSN(t *testing.T) {
+	t.Parallel()
+
+	lsn, err := pkgpostgres.ParseLSN("16/B374D848")
+	require.NoError(t, err)
+	assert.Equal(t, pkgpostgres.LSN(0x16B374D848), lsn)
+	assert.Equal(t, "16/B374D848", lsn.String())
+
+	_, err = pkgpostgres.ParseLSN("B374D848")
+	assert.Error(t, err)
+}
+
+func TestNewChangeConsumer(t *testing.T) {
+	t.Parallel()
+
+	handler := func(context.Context, pkgpostgres.ChangeEvent) error { return nil }
+	newConfig := func() pkgpostgres.ChangeConsumerConfig {
+		return pkgpostgres.ChangeConsumerConfig{
+			Conn:            pkgpostgres.SQLConnConfig{DSN: "postgres://postgres@localhost:5432/postgres"},
+			SlotName:        "search_indexer",
+			PublicationName: "search_indexer",
+			Handler:         handler,
+		}
+	}
+
+	consumer, err := pkgpostgres.NewChangeConsumer(newConfig())
+	require.NoError(t, err)
+	assert.Equal(t, pkgpostgres.LSN(0), consumer.AckedLSN())
+
+	cfg := newConfig()
+	cfg.SlotName = "Search-Indexer"
+	_, err = pkgpostgres.NewChangeConsumer(cfg)
+	assert.Error(t, err)
+
+	cfg = newConfig()
+	cfg.Handler = nil
+	_, err = pkgpostgres.NewChangeConsumer(cfg)
+	assert.Error(t, err)
+}
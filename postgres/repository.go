@@ -0,0 +1,307 @@
+package pkgpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	DefaultDeletedAtColumn = "deleted_at"
+	DefaultVersionColumn   = "version"
+)
+
+// ErrConcurrentModification is matched by ConcurrentModificationError
+// with errors.Is.
+var ErrConcurrentModification = errors.New("concurrent modification")
+
+// ConcurrentModificationError is returned by UpdateWithVersion when no row
+// matched the key and the expected version, i.e. the row was modified or
+// deleted since it was read. Check for it with errors.Is and
+// ErrConcurrentModification, or with errors.As to get the details.
+type ConcurrentModificationError struct {
+	Table   string
+	Key     Values
+	Version int64
+}
+
+func (e *ConcurrentModificationError) Error() string {
+	return fmt.Sprintf("concurrent modification of %s %v: version %d doesn't match", e.Table, e.Key, e.Version)
+}
+
+func (e *ConcurrentModificationError) Is(target error) bool {
+	return target == ErrConcurrentModification
+}
+
+// Values maps the column names to their values. The columns are used in
+// the statements in the sorted order, so the same set of columns always
+// produces the same statement.
+type Values map[string]any
+
+func (v Values) columns() []string {
+	columns := make([]string, 0, len(v))
+	for c := range v {
+		columns = append(columns, c)
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+// Table describes the table used by the repository helpers.
+type Table struct {
+	// Name is the possibly schema qualified name of the table.
+	Name string
+	// DeletedAtColumn is the timestamp column set by SoftDelete.
+	// DefaultDeletedAtColumn is used if it is empty.
+	DeletedAtColumn string
+	// VersionColumn is the integer column incremented by UpdateWithVersion.
+	// DefaultVersionColumn is used if it is empty.
+	VersionColumn string
+}
+
+// NotDeleted returns the condition that filters out the soft deleted rows,
+// e.g. "deleted_at" IS NULL, to be used in the queries of the table.
+func (t Table) NotDeleted() string {
+	return t.deletedAtColumn() + " IS NULL"
+}
+
+func (t Table) deletedAtColumn() string {
+	if t.DeletedAtColumn == "" {
+		return quoteIdentifier(DefaultDeletedAtColumn)
+	}
+
+	return quoteIdentifier(t.DeletedAtColumn)
+}
+
+func (t Table) versionColumn() string {
+	if t.VersionColumn == "" {
+		return quoteIdentifier(DefaultVersionColumn)
+	}
+
+	return quoteIdentifier(t.VersionColumn)
+}
+
+func (t Table) name() string {
+	return parseIdentifier(t.Name).Sanitize()
+}
+
+type UpsertOptions struct {
+	// ConflictColumns is the conflict target, e.g. the columns of the
+	// primary key or a unique index.
+	ConflictColumns []string
+	// ConflictWhere is the predicate of the partial unique index used as
+	// the conflict target.
+	ConflictWhere string
+	// UpdateColumns are updated with the new values on conflict. All the
+	// values except ConflictColumns are updated if it is nil.
+	UpdateColumns []string
+	// DoNothing leaves the conflicting row intact instead of updating it.
+	DoNothing bool
+}
+
+// Upsert inserts the row or updates the conflicting one and reports
+// whether the row was inserted. It reports false when the conflicting
+// row was updated or left intact with DoNothing.
+func Upsert(ctx context.Context, q pkgsql.Querier, table Table, values Values, opts UpsertOptions) (bool, error) {
+	if len(values) == 0 {
+		return false, fmt.Errorf("values are required")
+	}
+
+	if len(opts.ConflictColumns) == 0 {
+		return false, fmt.Errorf("conflict columns are required")
+	}
+
+	columns := values.columns()
+	args := make([]any, 0, len(columns))
+	placeholders := make([]string, 0, len(columns))
+	for i, c := range columns {
+		args = append(args, values[c])
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s)",
+		table.name(), quoteIdentifiers(columns), strings.Join(placeholders, ", "), quoteIdentifiers(opts.ConflictColumns))
+
+	if opts.ConflictWhere != "" {
+		sb.WriteString(" WHERE " + opts.ConflictWhere)
+	}
+
+	updateColumns := opts.UpdateColumns
+	if updateColumns == nil {
+		conflict := make(map[string]struct{}, len(opts.ConflictColumns))
+		for _, c := range opts.ConflictColumns {
+			conflict[c] = struct{}{}
+		}
+
+		for _, c := range columns {
+			if _, ok := conflict[c]; !ok {
+				updateColumns = append(updateColumns, c)
+			}
+		}
+	}
+
+	if opts.DoNothing || len(updateColumns) == 0 {
+		sb.WriteString(" DO NOTHING")
+	} else {
+		set := make([]string, 0, len(updateColumns))
+		for _, c := range updateColumns {
+			set = append(set, fmt.Sprintf("%s = EXCLUDED.%[1]s", quoteIdentifier(c)))
+		}
+		sb.WriteString(" DO UPDATE SET " + strings.Join(set, ", "))
+	}
+
+	// xmax is zero for the rows inserted by the statement.
+	sb.WriteString(" RETURNING xmax = 0")
+
+	rows, err := q.QueryContext(ctx, sb.String(), args...)
+	if err != nil {
+		return false, fmt.Errorf("failed to upsert into %s: %w", table.Name, err)
+	}
+	defer rows.Close()
+
+	var inserted bool
+	if rows.Next() {
+		err = rows.Scan(&inserted)
+	} else {
+		err = rows.Err()
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to upsert into %s: %w", table.Name, err)
+	}
+
+	return inserted, nil
+}
+
+// SoftDelete sets the deleted at column of the row matching the key to
+// the current time. It returns sql.ErrNoRows if there is no such row or
+// it is already deleted.
+func SoftDelete(ctx context.Context, e pkgsql.Execer, table Table, key Values) error {
+	where, args, err := keyCondition(key, 0)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = now() WHERE %s AND %s",
+		table.name(), table.deletedAtColumn(), where, table.NotDeleted())
+
+	return execAffectingRows(ctx, e, query, args, "soft delete", table)
+}
+
+// Restore clears the deleted at column of the row matching the key.
+// It returns sql.ErrNoRows if there is no such row or it is not deleted.
+func Restore(ctx context.Context, e pkgsql.Execer, table Table, key Values) error {
+	where, args, err := keyCondition(key, 0)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = NULL WHERE %s AND %s IS NOT NULL",
+		table.name(), table.deletedAtColumn(), where, table.deletedAtColumn())
+
+	return execAffectingRows(ctx, e, query, args, "restore", table)
+}
+
+// UpdateWithVersion updates the row matching the key only if its version
+// column equals version and increments the version, so the new version
+// is version+1. It returns *ConcurrentModificationError if no row matched.
+func UpdateWithVersion(ctx context.Context, e pkgsql.Execer, table Table, key Values, version int64, set Values) error {
+	if len(set) == 0 {
+		return fmt.Errorf("values to set are required")
+	}
+
+	columns := set.columns()
+	args := make([]any, 0, len(columns)+len(key)+1)
+	assignments := make([]string, 0, len(columns)+1)
+	for _, c := range columns {
+		args = append(args, set[c])
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", quoteIdentifier(c), len(args)))
+	}
+	assignments = append(assignments, fmt.Sprintf("%s = %[1]s + 1", table.versionColumn()))
+
+	where, keyArgs, err := keyCondition(key, len(args))
+	if err != nil {
+		return err
+	}
+	args = append(args, keyArgs...)
+	args = append(args, version)
+
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s AND %s = $%d",
+		table.name(), strings.Join(assignments, ", "), where, table.versionColumn(), len(args))
+
+	res, err := e.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", table.Name, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", table.Name, err)
+	}
+
+	if n == 0 {
+		return &ConcurrentModificationError{
+			Table:   table.Name,
+			Key:     key,
+			Version: version,
+		}
+	}
+
+	return nil
+}
+
+// keyCondition returns the condition matching the key with the
+// placeholders numbered after offset.
+func keyCondition(key Values, offset int) (string, []any, error) {
+	if len(key) == 0 {
+		return "", nil, fmt.Errorf("key is required")
+	}
+
+	columns := key.columns()
+	conditions := make([]string, 0, len(columns))
+	args := make([]any, 0, len(columns))
+	for i, c := range columns {
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", quoteIdentifier(c), offset+i+1))
+		args = append(args, key[c])
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+func execAffectingRows(ctx context.Context, e pkgsql.Execer, query string, args []any, op string, table Table) error {
+	res, err := e.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", op, table.Name, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to %s %s: %w", op, table.Name, err)
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func quoteIdentifier(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quoteIdentifier(name))
+	}
+
+	return strings.Join(quoted, ", ")
+}
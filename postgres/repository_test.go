@@ -0,0 +1,273 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type rowsAffected int64
+
+func (r rowsAffected) LastInsertId() (int64, error) {
+	return 0, errors.New("not supported")
+}
+
+func (r rowsAffected) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+// stubStatement is a statement run on stubConn. Transaction boundaries
+// are recorded as BEGIN, COMMIT and ROLLBACK.
+type stubStatement struct {
+	query string
+	args  []any
+}
+
+// stubConn is a driver.Conn that records the statements. The queries are
+// answered with the rows returned by query, exec returns the result of
+// the other statements. Both can be nil.
+type stubConn struct {
+	query func(query string, args []any) (columns []string, rows [][]driver.Value, err error)
+	exec  func(query string, args []any) error
+
+	mu         sync.Mutex
+	statements []stubStatement
+}
+
+// newStubDB returns sql.DB that runs all the statements on conn.
+func newStubDB(t *testing.T, conn *stubConn) *sql.DB {
+	t.Helper()
+
+	db := sql.OpenDB(stubConnector{conn: conn})
+	t.Cleanup(func() { _ = db.Close() })
+
+	return db
+}
+
+func (c *stubConn) Statements() []stubStatement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.statements
+}
+
+func (c *stubConn) record(query string, args []driver.NamedValue) []any {
+	values := make([]any, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+
+	c.mu.Lock()
+	c.statements = append(c.statements, stubStatement{query: query, args: values})
+	c.mu.Unlock()
+
+	return values
+}
+
+func (c *stubConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *stubConn) Close() error {
+	return nil
+}
+
+func (c *stubConn) Begin() (driver.Tx, error) {
+	c.record("BEGIN", nil)
+
+	return stubTx{conn: c}, nil
+}
+
+func (c *stubConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := c.record(query, args)
+	if c.exec != nil {
+		err := c.exec(query, values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (c *stubConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	values := c.record(query, args)
+	if c.query == nil {
+		return &stubRows{}, nil
+	}
+
+	columns, rows, err := c.query(query, values)
+	if err != nil {
+		return nil, err
+	}
+
+	return &stubRows{columns: columns, rows: rows}, nil
+}
+
+type stubTx struct {
+	conn *stubConn
+}
+
+func (tx stubTx) Commit() error {
+	tx.conn.record("COMMIT", nil)
+
+	return nil
+}
+
+func (tx stubTx) Rollback() error {
+	tx.conn.record("ROLLBACK", nil)
+
+	return nil
+}
+
+type stubRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string {
+	return r.columns
+}
+
+func (r *stubRows) Close() error {
+	return nil
+}
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+type stubConnector struct {
+	conn *stubConn
+}
+
+func (c stubConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+func (c stubConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestUpsert(t *testing.T) {
+	t.Parallel()
+
+	values := pkgpostgres.Values{"id": 7, "name": "new", "balance": 10}
+
+	tests := []struct {
+		name     string
+		opts     pkgpostgres.UpsertOptions
+		rows     [][]driver.Value
+		query    string
+		inserted bool
+	}{
+		{
+			name:     "inserted",
+			opts:     pkgpostgres.UpsertOptions{ConflictColumns: []string{"id"}},
+			rows:     [][]driver.Value{{true}},
+			query:    `INSERT INTO "app"."accounts" ("balance", "id", "name") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "balance" = EXCLUDED."balance", "name" = EXCLUDED."name" RETURNING xmax = 0`,
+			inserted: true,
+		},
+		{
+			name:  "updated",
+			opts:  pkgpostgres.UpsertOptions{ConflictColumns: []string{"id"}, UpdateColumns: []string{"name"}},
+			rows:  [][]driver.Value{{false}},
+			query: `INSERT INTO "app"."accounts" ("balance", "id", "name") VALUES ($1, $2, $3) ON CONFLICT ("id") DO UPDATE SET "name" = EXCLUDED."name" RETURNING xmax = 0`,
+		},
+		{
+			name:  "partial index",
+			opts:  pkgpostgres.UpsertOptions{ConflictColumns: []string{"name"}, ConflictWhere: "deleted_at IS NULL"},
+			rows:  [][]driver.Value{{false}},
+			query: `INSERT INTO "app"."accounts" ("balance", "id", "name") VALUES ($1, $2, $3) ON CONFLICT ("name") WHERE deleted_at IS NULL DO UPDATE SET "balance" = EXCLUDED."balance", "id" = EXCLUDED."id" RETURNING xmax = 0`,
+		},
+		{
+			// The conflicting row is not returned with DO NOTHING.
+			name:  "do nothing",
+			opts:  pkgpostgres.UpsertOptions{ConflictColumns: []string{"id"}, DoNothing: true},
+			query: `INSERT INTO "app"."accounts" ("balance", "id", "name") VALUES ($1, $2, $3) ON CONFLICT ("id") DO NOTHING RETURNING xmax = 0`,
+		},
+		{
+			name:  "nothing to update",
+			opts:  pkgpostgres.UpsertOptions{ConflictColumns: []string{"balance", "id", "name"}},
+			query: `INSERT INTO "app"."accounts" ("balance", "id", "name") VALUES ($1, $2, $3) ON CONFLICT ("balance", "id", "name") DO NOTHING RETURNING xmax = 0`,
+		},
+	}
+
+	for _, tt := range tests {
+		rows := tt.rows
+		conn := &stubConn{query: func(string, []any) ([]string, [][]driver.Value, error) {
+			return []string{"?column?"}, rows, nil
+		}}
+		db := newStubDB(t, conn)
+
+		inserted, err := pkgpostgres.Upsert(context.Background(), db, pkgpostgres.Table{Name: "app.accounts"}, values, tt.opts)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.inserted, inserted, tt.name)
+		assert.Equal(t, []stubStatement{{query: tt.query, args: []any{int64(10), int64(7), "new"}}}, conn.Statements(), tt.name)
+	}
+
+	_, err := pkgpostgres.Upsert(context.Background(), nil, pkgpostgres.Table{Name: "accounts"}, values, pkgpostgres.UpsertOptions{})
+	assert.ErrorContains(t, err, "conflict columns are required")
+}
+
+func TestUpdateWithVersion(t *testing.T) {
+	t.Parallel()
+
+	var (
+		query string
+		args  []any
+	)
+	execer := execerFunc(func(_ context.Context, q string, a ...any) (sql.Result, error) {
+		query, args = q, a
+
+		return rowsAffected(0), nil
+	})
+
+	table := pkgpostgres.Table{Name: "app.accounts"}
+	err := pkgpostgres.UpdateWithVersion(context.Background(), execer, table,
+		pkgpostgres.Values{"id": 7}, 3, pkgpostgres.Values{"name": "new", "balance": 10})
+
+	assert.ErrorIs(t, err, pkgpostgres.ErrConcurrentModification)
+	var modErr *pkgpostgres.ConcurrentModificationError
+	require.ErrorAs(t, err, &modErr)
+	assert.Equal(t, int64(3), modErr.Version)
+	assert.Equal(t, `UPDATE "app"."accounts" SET "balance" = $1, "name" = $2, "version" = "version" + 1 WHERE "id" = $3 AND "version" = $4`, query)
+	assert.Equal(t, []any{10, "new", 7, int64(3)}, args)
+}
+
+func TestSoftDelete(t *testing.T) {
+	t.Parallel()
+
+	var query string
+	affected := rowsAffected(1)
+	execer := execerFunc(func(_ context.Context, q string, _ ...any) (sql.Result, error) {
+		query = q
+
+		return affected, nil
+	})
+
+	table := pkgpostgres.Table{Name: "accounts", DeletedAtColumn: "removed_at"}
+	require.NoError(t, pkgpostgres.SoftDelete(context.Background(), execer, table, pkgpostgres.Values{"id": 7}))
+	assert.Equal(t, `UPDATE "accounts" SET "removed_at" = now() WHERE "id" = $1 AND "removed_at" IS NULL`, query)
+
+	affected = 0
+	assert.ErrorIs(t, pkgpostgres.Restore(context.Background(), execer, table, pkgpostgres.Values{"id": 7}), sql.ErrNoRows)
+	assert.Equal(t, `UPDATE "accounts" SET "removed_at" = NULL WHERE "id" = $1 AND "removed_at" IS NOT NULL`, query)
+
+	assert.Error(t, pkgpostgres.SoftDelete(context.Background(), execer, table, nil))
+}
@@ -0,0 +1,86 @@
+package pkgpostgres
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/avast/retry-go"
+)
+
+// nonTransientConnectErrors are the errors returned while connecting to
+// the database that will not go away by retrying.
+var nonTransientConnectErrors = []ErrorCode{
+	Err28000, // InvalidAuthorizationSpecification
+	Err28P01, // InvalidPassword
+	Err3D000, // InvalidCatalogName
+}
+
+// OnRetryFunc is called after every failed connection attempt.
+// The attempt number starts from 0.
+type OnRetryFunc func(attempt uint, err error)
+
+type retryConnectConfig struct {
+	attempts  uint
+	delay     time.Duration
+	maxDelay  time.Duration
+	maxJitter time.Duration
+	onRetry   OnRetryFunc
+}
+
+// IsNonTransientConnectError checks if the error returned while connecting
+// to the database is permanent, e.g. an invalid password or a database
+// that doesn't exist, so there is no point to retry the connection.
+func IsNonTransientConnectError(err error) bool {
+	return IsErrorCodeOneOf(err, nonTransientConnectErrors...)
+}
+
+// retryConnect calls connect until it succeeds using exponential backoff
+// capped by maxDelay with jitter. Non-transient errors are returned
+// immediately. At least one attempt is required.
+func retryConnect(ctx context.Context, cfg retryConnectConfig, connect func() error) error {
+	if cfg.attempts == 0 {
+		return errors.New("retry connect attempts must be positive")
+	}
+
+	opts := []retry.Option{
+		retry.Attempts(cfg.attempts),
+		retry.DelayType(func(n uint, _ error, _ *retry.Config) time.Duration {
+			return retryDelay(cfg, n)
+		}),
+		retry.RetryIf(func(err error) bool {
+			return !IsNonTransientConnectError(err)
+		}),
+		retry.LastErrorOnly(true),
+		retry.Context(ctx),
+	}
+
+	if cfg.onRetry != nil {
+		opts = append(opts, retry.OnRetry(retry.OnRetryFunc(cfg.onRetry)))
+	}
+
+	return retry.Do(connect, opts...)
+}
+
+// retryDelay returns the delay after the failed attempt n starting from 0.
+// The jitter is added after the delay is capped by maxDelay, so the
+// clients that reached maxDelay don't reconnect at the same moment.
+func retryDelay(cfg retryConnectConfig, n uint) time.Duration {
+	delay := backoffDelay(int(n)+1, cfg.delay, cfg.maxDelay)
+	if cfg.maxJitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(cfg.maxJitter)))
+	}
+
+	return delay
+}
+
+// backoffDelay returns the delay before the next attempt. The delay
+// doubles after every attempt and is capped by maxDelay.
+func backoffDelay(attempt int, delay, maxDelay time.Duration) time.Duration {
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
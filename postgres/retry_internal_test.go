@@ -0,0 +1,79 @@
+package pkgpostgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryConnect(t *testing.T) {
+	t.Parallel()
+
+	errConnect := errors.New("connection refused")
+	cfg := retryConnectConfig{delay: time.Millisecond, maxDelay: time.Millisecond}
+
+	var calls int
+	connect := func() error {
+		calls++
+
+		return errConnect
+	}
+
+	err := retryConnect(context.Background(), cfg, connect)
+	assert.Error(t, err)
+	assert.Equal(t, 0, calls)
+
+	cfg.attempts = 3
+	err = retryConnect(context.Background(), cfg, connect)
+	assert.ErrorIs(t, err, errConnect)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	cfg := retryConnectConfig{delay: time.Second, maxDelay: time.Second * 4, maxJitter: time.Millisecond * 500}
+
+	delays := make(map[time.Duration]struct{})
+	for i := 0; i < 100; i++ {
+		// The delay reached maxDelay, the jitter is still applied.
+		delay := retryDelay(cfg, 10)
+		assert.GreaterOrEqual(t, delay, cfg.maxDelay)
+		assert.Less(t, delay, cfg.maxDelay+cfg.maxJitter)
+		delays[delay] = struct{}{}
+	}
+	assert.Greater(t, len(delays), 1)
+
+	cfg.maxJitter = 0
+	assert.Equal(t, time.Second, retryDelay(cfg, 0))
+	assert.Equal(t, time.Second*2, retryDelay(cfg, 1))
+	assert.Equal(t, time.Second*4, retryDelay(cfg, 5))
+}
+
+func TestBackoffDelay(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: -1, want: time.Second},
+		{attempt: 0, want: time.Second},
+		{attempt: 1, want: time.Second},
+		{attempt: 2, want: time.Second * 2},
+		{attempt: 3, want: time.Second * 4},
+		{attempt: 4, want: time.Second * 8},
+		{attempt: 5, want: time.Second * 10},
+		{attempt: 100, want: time.Second * 10},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, backoffDelay(tt.attempt, time.Second, time.Second*10), "attempt %d", tt.attempt)
+	}
+
+	// The initial delay is capped too.
+	assert.Equal(t, time.Second, backoffDelay(1, time.Minute, time.Second))
+}
@@ -0,0 +1,163 @@
+package pkgpostgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// AfterConnectFunc is called on every new physical connection before
+// it is used, e.g. to register custom types or prepare statements.
+type AfterConnectFunc func(ctx context.Context, conn *pgx.Conn) error
+
+// SessionSettings are the session parameters set on every new connection.
+// They are sent in the startup message, so setting them costs no round trips.
+type SessionSettings struct {
+	// StatementTimeout aborts the statements that run longer than that.
+	StatementTimeout *time.Duration
+	// LockTimeout aborts the statements that wait for a lock longer than that.
+	LockTimeout *time.Duration
+	// IdleInTransactionSessionTimeout terminates the sessions that are idle
+	// within an open transaction longer than that.
+	IdleInTransactionSessionTimeout *time.Duration
+	// SearchPath is the list of schemas the unqualified names are looked up in.
+	SearchPath      []string
+	ApplicationName *string
+	TimeZone        *string
+	// Params are other runtime parameters, e.g. work_mem. They override
+	// the typed fields.
+	Params map[string]string
+}
+
+func (s *SessionSettings) Validate() error {
+	timeouts := map[string]*time.Duration{
+		"statement_timeout":                   s.StatementTimeout,
+		"lock_timeout":                        s.LockTimeout,
+		"idle_in_transaction_session_timeout": s.IdleInTransactionSessionTimeout,
+	}
+
+	for name, timeout := range timeouts {
+		if timeout != nil && *timeout < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+
+	for _, schema := range s.SearchPath {
+		if schema == "" {
+			return fmt.Errorf("search path must not contain empty schema")
+		}
+	}
+
+	return nil
+}
+
+// RuntimeParams returns the settings as the runtime parameters of the
+// connection. The timeouts are in milliseconds, zero disables them.
+func (s SessionSettings) RuntimeParams() map[string]string {
+	params := make(map[string]string)
+
+	timeouts := map[string]*time.Duration{
+		"statement_timeout":                   s.StatementTimeout,
+		"lock_timeout":                        s.LockTimeout,
+		"idle_in_transaction_session_timeout": s.IdleInTransactionSessionTimeout,
+	}
+	for name, timeout := range timeouts {
+		if timeout != nil {
+			params[name] = strconv.FormatInt(timeout.Milliseconds(), 10)
+		}
+	}
+
+	if len(s.SearchPath) > 0 {
+		schemas := make([]string, 0, len(s.SearchPath))
+		for _, schema := range s.SearchPath {
+			schemas = append(schemas, pgx.Identifier{schema}.Sanitize())
+		}
+		params["search_path"] = strings.Join(schemas, ", ")
+	}
+
+	if s.ApplicationName != nil {
+		params["application_name"] = *s.ApplicationName
+	}
+
+	if s.TimeZone != nil {
+		params["timezone"] = *s.TimeZone
+	}
+
+	for k, v := range s.Params {
+		params[k] = v
+	}
+
+	return params
+}
+
+// configureConnConfig applies the session settings to the connection config.
+func configureConnConfig(connCfg *pgx.ConnConfig, session SessionSettings) {
+	if connCfg.RuntimeParams == nil {
+		connCfg.RuntimeParams = make(map[string]string)
+	}
+
+	for k, v := range session.RuntimeParams() {
+		connCfg.RuntimeParams[k] = v
+	}
+}
+
+// afterConnect registers the custom types and then calls fn if it is not nil.
+func afterConnect(fn AfterConnectFunc) AfterConnectFunc {
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		RegisterDecimalType(conn.TypeMap())
+
+		if fn == nil {
+			return nil
+		}
+
+		err := fn(ctx, conn)
+		if err != nil {
+			return fmt.Errorf("after connect hook failed: %w", err)
+		}
+
+		return nil
+	}
+}
+
+// RegisterDecimalType registers pkgdecimal.Decimal as numeric in the type
+// map, so decimals are sent to the server exactly in the binary format.
+// The connections created by SQLConn, PoolConn and Listener have it
+// registered already.
+func RegisterDecimalType(m *pgtype.Map) {
+	m.TryWrapEncodePlanFuncs = append([]pgtype.TryWrapEncodePlanFunc{tryWrapDecimalEncodePlan}, m.TryWrapEncodePlanFuncs...)
+	m.RegisterDefaultPgType(pkgdecimal.Decimal{}, "numeric")
+	m.RegisterDefaultPgType(&pkgdecimal.Decimal{}, "numeric")
+}
+
+// numericDecimal is pkgdecimal.Decimal that implements pgtype.NumericValuer.
+type numericDecimal pkgdecimal.Decimal
+
+func (d numericDecimal) NumericValue() (pgtype.Numeric, error) {
+	return decimalToNumeric(pkgdecimal.Decimal(d))
+}
+
+type wrapDecimalEncodePlan struct {
+	next pgtype.EncodePlan
+}
+
+func (p *wrapDecimalEncodePlan) SetNext(next pgtype.EncodePlan) {
+	p.next = next
+}
+
+func (p *wrapDecimalEncodePlan) Encode(value any, buf []byte) ([]byte, error) {
+	return p.next.Encode(numericDecimal(value.(pkgdecimal.Decimal)), buf)
+}
+
+func tryWrapDecimalEncodePlan(value any) (pgtype.WrappedEncodePlanNextSetter, any, bool) {
+	if d, ok := value.(pkgdecimal.Decimal); ok {
+		return &wrapDecimalEncodePlan{}, numericDecimal(d), true
+	}
+
+	return nil, nil, false
+}
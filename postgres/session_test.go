@@ -0,0 +1,57 @@
+package pkgpostgres_test
+
+import (
+	"testing"
+	"time"
+
+	pkgdecimal "github.com/amanbolat/pkg/decimal"
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionSettings_RuntimeParams(t *testing.T) {
+	t.Parallel()
+
+	s := pkgpostgres.SessionSettings{
+		StatementTimeout: pkgptr.Ptr(time.Second * 5),
+		LockTimeout:      pkgptr.Ptr(time.Duration(0)),
+		SearchPath:       []string{"app", "public"},
+		TimeZone:         pkgptr.Ptr("UTC"),
+		Params:           map[string]string{"work_mem": "64MB"},
+	}
+	require.NoError(t, s.Validate())
+
+	assert.Equal(t, map[string]string{
+		"statement_timeout": "5000",
+		"lock_timeout":      "0",
+		"search_path":       `"app", "public"`,
+		"timezone":          "UTC",
+		"work_mem":          "64MB",
+	}, s.RuntimeParams())
+
+	s.LockTimeout = pkgptr.Ptr(-time.Second)
+	assert.Error(t, s.Validate())
+}
+
+func TestRegisterDecimalType(t *testing.T) {
+	t.Parallel()
+
+	m := pgtype.NewMap()
+	pkgpostgres.RegisterDecimalType(m)
+
+	typ, ok := m.TypeForValue(pkgdecimal.Decimal{})
+	require.True(t, ok)
+	assert.Equal(t, uint32(pgtype.NumericOID), typ.OID)
+
+	buf, err := m.Encode(pgtype.NumericOID, pgtype.BinaryFormatCode, pkgdecimal.MustFromStr("12345678901234567890.0123456789"), nil)
+	require.NoError(t, err)
+
+	var n pgtype.Numeric
+	err = m.Scan(pgtype.NumericOID, pgtype.BinaryFormatCode, buf, &n)
+	require.NoError(t, err)
+	assert.Equal(t, "123456789012345678900123456789", n.Int.String())
+	assert.Equal(t, int32(-10), n.Exp)
+}
@@ -7,32 +7,59 @@ import (
 	"time"
 
 	pkgptr "github.com/amanbolat/pkg/ptr"
-	"github.com/avast/retry-go"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 )
 
 const (
-	DefaultRetryConnectAttempts = 10
-	DefaultRetryConnectDelay    = time.Second * 3
-	DefaultMaxOpenConns         = 15
-	DefaultMaxIdleConns         = 15
-	DefaultConnMaxLifetime      = time.Minute * 5
+	DefaultRetryConnectAttempts  = 10
+	DefaultRetryConnectDelay     = time.Millisecond * 500
+	DefaultRetryConnectMaxDelay  = time.Second * 10
+	DefaultRetryConnectMaxJitter = time.Millisecond * 500
+	DefaultMaxOpenConns          = 15
+	DefaultMaxIdleConns          = 15
+	DefaultConnMaxLifetime       = time.Minute * 5
 )
 
 // SQLConn is a wrapper around sql.DB that uses pgx driver under the hood.
 type SQLConn struct {
 	*sql.DB
+	healthCheckQuery   string
+	healthCheckTimeout time.Duration
 }
 
 type SQLConnConfig struct {
-	DSN                  string
+	// DSN is the connection string, e.g. built with DSNConfig.URL.
+	DSN string
+	// RetryConnectAttempts is the maximum number of connection attempts.
+	// It must be positive.
 	RetryConnectAttempts *uint
-	RetryConnectDelay    *time.Duration
-	MaxOpenConns         *int
-	MaxIdleConns         *int
-	ConnMaxLifetime      *time.Duration
-	ConnOptions          []stdlib.OptionOpenDB
+	// RetryConnectDelay is the delay after the first failed attempt.
+	RetryConnectDelay *time.Duration
+	// RetryConnectMaxDelay caps the exponentially growing delay between attempts.
+	RetryConnectMaxDelay *time.Duration
+	// RetryConnectMaxJitter is the maximum random duration added to the delay
+	// after it is capped by RetryConnectMaxDelay.
+	RetryConnectMaxJitter *time.Duration
+	// OnRetry is called after every failed connection attempt.
+	OnRetry         OnRetryFunc
+	MaxOpenConns    *int
+	MaxIdleConns    *int
+	ConnMaxLifetime *time.Duration
+	// Session are the session parameters set on every new connection.
+	Session SessionSettings
+	// AfterConnect is called on every new connection after the custom
+	// types, e.g. pkgdecimal.Decimal, are registered.
+	AfterConnect AfterConnectFunc
+	// PasswordProvider is consulted for the password on every new connection.
+	PasswordProvider PasswordProvider
+	// ConnOptions are applied after Session, AfterConnect and PasswordProvider,
+	// so they take precedence, e.g. stdlib.OptionAfterConnect replaces AfterConnect.
+	ConnOptions []stdlib.OptionOpenDB
+	// HealthCheckQuery is the query run by SQLConn.HealthCheck.
+	HealthCheckQuery *string
+	// HealthCheckTimeout is the timeout of SQLConn.HealthCheck.
+	HealthCheckTimeout *time.Duration
 }
 
 func (c *SQLConnConfig) Validate() error {
@@ -44,10 +71,22 @@ func (c *SQLConnConfig) Validate() error {
 		c.RetryConnectAttempts = pkgptr.Ptr(uint(DefaultRetryConnectAttempts))
 	}
 
+	if *c.RetryConnectAttempts == 0 {
+		return fmt.Errorf("retry connect attempts must be positive")
+	}
+
 	if c.RetryConnectDelay == nil {
 		c.RetryConnectDelay = pkgptr.Ptr(DefaultRetryConnectDelay)
 	}
 
+	if c.RetryConnectMaxDelay == nil {
+		c.RetryConnectMaxDelay = pkgptr.Ptr(DefaultRetryConnectMaxDelay)
+	}
+
+	if c.RetryConnectMaxJitter == nil {
+		c.RetryConnectMaxJitter = pkgptr.Ptr(DefaultRetryConnectMaxJitter)
+	}
+
 	if c.MaxOpenConns == nil {
 		c.MaxOpenConns = pkgptr.Ptr(DefaultMaxOpenConns)
 	}
@@ -60,12 +99,27 @@ func (c *SQLConnConfig) Validate() error {
 		c.ConnMaxLifetime = pkgptr.Ptr(DefaultConnMaxLifetime)
 	}
 
+	if c.HealthCheckQuery == nil {
+		c.HealthCheckQuery = pkgptr.Ptr(DefaultHealthCheckQuery)
+	}
+
+	if c.HealthCheckTimeout == nil {
+		c.HealthCheckTimeout = pkgptr.Ptr(DefaultHealthCheckTimeout)
+	}
+
+	err := c.Session.Validate()
+	if err != nil {
+		return fmt.Errorf("invalid session settings: %w", err)
+	}
+
 	return nil
 }
 
 // NewSQLConn creates and returns a new postgres connection.
 // The function ensures that the connection is established correctly
-// by send a ping request to the database.
+// by send a ping request to the database. Failed attempts are retried
+// with exponential backoff unless the error is non-transient,
+// see IsNonTransientConnectError.
 func NewSQLConn(ctx context.Context, cfg SQLConnConfig) (*SQLConn, error) {
 	err := cfg.Validate()
 	if err != nil {
@@ -76,10 +130,22 @@ func NewSQLConn(ctx context.Context, cfg SQLConnConfig) (*SQLConn, error) {
 	if err != nil {
 		return nil, err
 	}
+	configureConnConfig(pgxCfg, cfg.Session)
+
+	opts := append([]stdlib.OptionOpenDB{
+		stdlib.OptionAfterConnect(afterConnect(cfg.AfterConnect)),
+		stdlib.OptionBeforeConnect(beforeConnect(cfg.PasswordProvider)),
+	}, cfg.ConnOptions...)
 
 	var db *sql.DB
-	err = retry.Do(func() error {
-		db = stdlib.OpenDB(*pgxCfg, cfg.ConnOptions...)
+	err = retryConnect(ctx, retryConnectConfig{
+		attempts:  *cfg.RetryConnectAttempts,
+		delay:     *cfg.RetryConnectDelay,
+		maxDelay:  *cfg.RetryConnectMaxDelay,
+		maxJitter: *cfg.RetryConnectMaxJitter,
+		onRetry:   cfg.OnRetry,
+	}, func() error {
+		db = stdlib.OpenDB(*pgxCfg, opts...)
 		connErr := db.Ping()
 		if connErr != nil {
 			_ = db.Close()
@@ -87,7 +153,7 @@ func NewSQLConn(ctx context.Context, cfg SQLConnConfig) (*SQLConn, error) {
 		}
 
 		return nil
-	}, retry.Attempts(*cfg.RetryConnectAttempts), retry.Delay(*cfg.RetryConnectDelay), retry.DelayType(retry.FixedDelay), retry.Context(ctx))
+	})
 
 	if err != nil {
 		return nil, err
@@ -96,7 +162,11 @@ func NewSQLConn(ctx context.Context, cfg SQLConnConfig) (*SQLConn, error) {
 	db.SetMaxIdleConns(*cfg.MaxIdleConns)
 	db.SetConnMaxLifetime(*cfg.ConnMaxLifetime)
 
-	c := &SQLConn{db}
+	c := &SQLConn{
+		DB:                 db,
+		healthCheckQuery:   *cfg.HealthCheckQuery,
+		healthCheckTimeout: *cfg.HealthCheckTimeout,
+	}
 
 	return c, nil
 }
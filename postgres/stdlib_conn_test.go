@@ -0,0 +1,34 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSQLConn_RetryConnectAttempts(t *testing.T) {
+	t.Parallel()
+
+	_, err := pkgpostgres.NewSQLConn(context.Background(), pkgpostgres.SQLConnConfig{
+		DSN:                  "postgres://app@127.0.0.1:1/app",
+		RetryConnectAttempts: pkgptr.Ptr(uint(0)),
+	})
+	assert.ErrorContains(t, err, "retry connect attempts must be positive")
+
+	var retries []uint
+	_, err = pkgpostgres.NewSQLConn(context.Background(), pkgpostgres.SQLConnConfig{
+		DSN:                   "postgres://app@127.0.0.1:1/app",
+		RetryConnectAttempts:  pkgptr.Ptr(uint(2)),
+		RetryConnectDelay:     pkgptr.Ptr(time.Millisecond),
+		RetryConnectMaxJitter: pkgptr.Ptr(time.Duration(0)),
+		OnRetry: func(attempt uint, _ error) {
+			retries = append(retries, attempt)
+		},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, []uint{0, 1}, retries)
+}
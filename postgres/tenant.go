@@ -0,0 +1,169 @@
+package pkgpostgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	pkgsql "github.com/amanbolat/pkg/sql"
+)
+
+const (
+	DefaultTenantSetting = "app.tenant_id"
+	DefaultUserSetting   = "app.user_id"
+)
+
+var (
+	// ErrNoTenant is returned by TenantDB.BeginTx in strict mode when the
+	// context has no tenant.
+	ErrNoTenant = errors.New("no tenant in context")
+	// ErrNoTenantTx is returned by TenantDB in strict mode when a query
+	// is run outside of a tenant scoped transaction.
+	ErrNoTenantTx = errors.New("query outside of tenant scoped transaction")
+)
+
+// TenantIdentity identifies the tenant and the user on whose behalf the
+// queries are run.
+type TenantIdentity struct {
+	TenantID string
+	// UserID is optional. The user setting is not set if it is empty, so
+	// current_setting with missing_ok returns NULL or an empty string.
+	UserID string
+}
+
+type tenantContextKey struct{}
+
+// WithTenant returns a copy of the context with the tenant identity.
+func WithTenant(ctx context.Context, id TenantIdentity) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, id)
+}
+
+// TenantFromContext returns the tenant identity stored by WithTenant.
+func TenantFromContext(ctx context.Context) (TenantIdentity, bool) {
+	id, ok := ctx.Value(tenantContextKey{}).(TenantIdentity)
+
+	return id, ok && id.TenantID != ""
+}
+
+type TenantDBConfig struct {
+	// TenantSetting is the setting the tenant ID is stored in, so the row
+	// level security policies can read it with current_setting.
+	TenantSetting *string
+	// UserSetting is the setting the user ID is stored in.
+	UserSetting *string
+	// Strict refuses to begin transactions without a tenant and to run
+	// queries outside of transactions.
+	Strict bool
+}
+
+func (c *TenantDBConfig) Validate() error {
+	if c.TenantSetting == nil {
+		c.TenantSetting = pkgptr.Ptr(DefaultTenantSetting)
+	}
+
+	if c.UserSetting == nil {
+		c.UserSetting = pkgptr.Ptr(DefaultUserSetting)
+	}
+
+	// Custom settings must be qualified with a prefix, e.g. app.
+	for _, setting := range []string{*c.TenantSetting, *c.UserSetting} {
+		if !strings.Contains(setting, ".") {
+			return fmt.Errorf("setting %s must have a prefix, e.g. app.%[1]s", setting)
+		}
+	}
+
+	return nil
+}
+
+var _ pkgsql.Database = (*TenantDB)(nil)
+
+// TenantDB is pkgsql.Database that sets the tenant identity from the
+// context at the beginning of every transaction, so the row level
+// security policies can isolate the tenants. The settings are local to
+// the transaction, so they don't leak to other transactions that reuse
+// the pooled connection.
+//
+// Example:
+//
+//	CREATE POLICY tenant_isolation ON orders
+//	    USING (tenant_id = current_setting('app.tenant_id', true)::uuid);
+//
+//	tenantDB, err := pkgpostgres.NewTenantDB(conn, pkgpostgres.TenantDBConfig{Strict: true})
+//	store := pkgsql.NewAtomicStore(tenantDB, newStore)
+//
+//	ctx = pkgpostgres.WithTenant(ctx, pkgpostgres.TenantIdentity{TenantID: tenantID})
+//	err = store.Exec(ctx, func(ctx context.Context, s Store) error { ... })
+type TenantDB struct {
+	db  pkgsql.Database
+	cfg TenantDBConfig
+}
+
+// NewTenantDB creates a new TenantDB that begins the transactions on db.
+func NewTenantDB(db pkgsql.Database, cfg TenantDBConfig) (*TenantDB, error) {
+	err := cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid TenantDB config: %w", err)
+	}
+
+	return &TenantDB{
+		db:  db,
+		cfg: cfg,
+	}, nil
+}
+
+// BeginTx begins the transaction and sets the tenant identity from the
+// context in it. Without a tenant in the context it returns ErrNoTenant
+// in strict mode, otherwise the transaction has no tenant settings.
+func (d *TenantDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	id, ok := TenantFromContext(ctx)
+	if !ok && d.cfg.Strict {
+		return nil, ErrNoTenant
+	}
+
+	tx, err := d.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return tx, nil
+	}
+
+	// SET LOCAL doesn't accept parameters, set_config with is_local does the same.
+	query := "SELECT set_config($1, $2, true)"
+	args := []any{*d.cfg.TenantSetting, id.TenantID}
+	if id.UserID != "" {
+		query += ", set_config($3, $4, true)"
+		args = append(args, *d.cfg.UserSetting, id.UserID)
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to set tenant: %w", err), tx.Rollback())
+	}
+
+	return tx, nil
+}
+
+// ExecContext runs the query outside of a transaction. It returns
+// ErrNoTenantTx in strict mode.
+func (d *TenantDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if d.cfg.Strict {
+		return nil, ErrNoTenantTx
+	}
+
+	return d.db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext runs the query outside of a transaction. It returns
+// ErrNoTenantTx in strict mode.
+func (d *TenantDB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if d.cfg.Strict {
+		return nil, ErrNoTenantTx
+	}
+
+	return d.db.QueryContext(ctx, query, args...)
+}
@@ -0,0 +1,203 @@
+package pkgpostgres_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+
+	pkgpostgres "github.com/amanbolat/pkg/postgres"
+	pkgptr "github.com/amanbolat/pkg/ptr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errBeginTx = errors.New("begin tx")
+
+type beginTxFailingDB struct {
+	execerFunc
+}
+
+func (beginTxFailingDB) BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error) {
+	return nil, errBeginTx
+}
+
+func (beginTxFailingDB) QueryContext(context.Context, string, ...any) (*sql.Rows, error) {
+	return nil, nil
+}
+
+// tenantConn is a driver.Conn that records the set_config statements and
+// the transaction boundaries as BEGIN, COMMIT and ROLLBACK without arguments.
+type tenantConn struct {
+	exec func(args []any) error
+
+	mu         sync.Mutex
+	statements []stubStatement
+}
+
+func (c *tenantConn) record(query string, args []any) {
+	c.mu.Lock()
+	c.statements = append(c.statements, stubStatement{query: query, args: args})
+	c.mu.Unlock()
+}
+
+func (c *tenantConn) Statements() []stubStatement {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.statements
+}
+
+func (c *tenantConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *tenantConn) Close() error {
+	return nil
+}
+
+func (c *tenantConn) Begin() (driver.Tx, error) {
+	c.record("BEGIN", nil)
+
+	return tenantTx{conn: c}, nil
+}
+
+func (c *tenantConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]any, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+	c.record(query, values)
+
+	err := c.exec(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+type tenantTx struct {
+	conn *tenantConn
+}
+
+func (tx tenantTx) Commit() error {
+	tx.conn.record("COMMIT", nil)
+
+	return nil
+}
+
+func (tx tenantTx) Rollback() error {
+	tx.conn.record("ROLLBACK", nil)
+
+	return nil
+}
+
+type tenantConnector struct {
+	conn *tenantConn
+}
+
+func (c tenantConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+func (c tenantConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestTenantDB_Strict(t *testing.T) {
+	t.Parallel()
+
+	db := beginTxFailingDB{execerFunc: func(context.Context, string, ...any) (sql.Result, error) {
+		return rowsAffected(0), nil
+	}}
+
+	tenantDB, err := pkgpostgres.NewTenantDB(db, pkgpostgres.TenantDBConfig{Strict: true})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = tenantDB.BeginTx(ctx, nil)
+	assert.ErrorIs(t, err, pkgpostgres.ErrNoTenant)
+
+	_, err = tenantDB.BeginTx(pkgpostgres.WithTenant(ctx, pkgpostgres.TenantIdentity{TenantID: "acme"}), nil)
+	assert.ErrorIs(t, err, errBeginTx)
+
+	_, err = tenantDB.ExecContext(ctx, "DELETE FROM orders")
+	assert.ErrorIs(t, err, pkgpostgres.ErrNoTenantTx)
+
+	_, err = tenantDB.QueryContext(ctx, "SELECT * FROM orders")
+	assert.ErrorIs(t, err, pkgpostgres.ErrNoTenantTx)
+
+	lax, err := pkgpostgres.NewTenantDB(db, pkgpostgres.TenantDBConfig{})
+	require.NoError(t, err)
+
+	_, err = lax.ExecContext(ctx, "DELETE FROM orders")
+	assert.NoError(t, err)
+
+	_, err = lax.BeginTx(ctx, nil)
+	assert.ErrorIs(t, err, errBeginTx)
+
+	_, err = pkgpostgres.NewTenantDB(db, pkgpostgres.TenantDBConfig{TenantSetting: pkgptr.Ptr("tenant_id")})
+	assert.Error(t, err)
+}
+
+func TestTenantDB_BeginTx(t *testing.T) {
+	t.Parallel()
+
+	errSetConfig := errors.New("set_config failed")
+	conn := &tenantConn{exec: func(args []any) error {
+		if args[1] == "broken" {
+			return errSetConfig
+		}
+
+		return nil
+	}}
+
+	db := sql.OpenDB(tenantConnector{conn: conn})
+	t.Cleanup(func() { _ = db.Close() })
+
+	tenantDB, err := pkgpostgres.NewTenantDB(db, pkgpostgres.TenantDBConfig{Strict: true})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	tx, err := tenantDB.BeginTx(pkgpostgres.WithTenant(ctx, pkgpostgres.TenantIdentity{TenantID: "acme", UserID: "42"}), nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// The user setting is skipped without the user.
+	tx, err = tenantDB.BeginTx(pkgpostgres.WithTenant(ctx, pkgpostgres.TenantIdentity{TenantID: "acme"}), nil)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+
+	// The transaction is rolled back if the settings can't be set.
+	_, err = tenantDB.BeginTx(pkgpostgres.WithTenant(ctx, pkgpostgres.TenantIdentity{TenantID: "broken"}), nil)
+	assert.ErrorIs(t, err, errSetConfig)
+
+	assert.Equal(t, []stubStatement{
+		{query: "BEGIN"},
+		{query: "SELECT set_config($1, $2, true), set_config($3, $4, true)", args: []any{"app.tenant_id", "acme", "app.user_id", "42"}},
+		{query: "COMMIT"},
+		{query: "BEGIN"},
+		{query: "SELECT set_config($1, $2, true)", args: []any{"app.tenant_id", "acme"}},
+		{query: "COMMIT"},
+		{query: "BEGIN"},
+		{query: "SELECT set_config($1, $2, true)", args: []any{"app.tenant_id", "broken"}},
+		{query: "ROLLBACK"},
+	}, conn.Statements())
+}
+
+func TestTenantFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := pkgpostgres.TenantFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := pkgpostgres.WithTenant(context.Background(), pkgpostgres.TenantIdentity{TenantID: "acme", UserID: "42"})
+	id, ok := pkgpostgres.TenantFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, pkgpostgres.TenantIdentity{TenantID: "acme", UserID: "42"}, id)
+}
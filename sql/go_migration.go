@@ -0,0 +1,210 @@
+package pkgsql
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// goMigrationMarker is a body of the virtual migration file that
+// represents a Go migration. It is recognized by migrationDriver.
+//
+// Example:
+//
+//	-- +migrate Go up 20230504225253
+const goMigrationMarker = "-- +migrate Go"
+
+// GoMigrationFunc is a migration written in Go.
+// It is executed inside a transaction.
+type GoMigrationFunc func(ctx context.Context, tx Tx) error
+
+type goMigration struct {
+	title string
+	up    GoMigrationFunc
+	down  GoMigrationFunc
+}
+
+var (
+	goMigrationsMu sync.RWMutex
+	goMigrations   = make(map[uint]goMigration)
+)
+
+// RegisterGoMigration registers a migration written in Go. The migration
+// is applied by Migrator in version order together with SQL migrations.
+// The version must be given in the format of the migration files,
+// i.e. unix seconds for Flyway and 20060102150405 for go-migrate.
+// Either up or down may be nil if the migration has no such direction.
+// The migration is titled go_migration_<version> in the migration plan
+// and logs, use RegisterNamedGoMigration to give it another title.
+//
+// RegisterGoMigration is typically called from the init function and
+// panics if the version is already registered.
+func RegisterGoMigration(version uint, up, down GoMigrationFunc) {
+	RegisterNamedGoMigration(version, fmt.Sprintf("go_migration_%d", version), up, down)
+}
+
+// RegisterNamedGoMigration is like RegisterGoMigration, but the title
+// shown in the migration plan and logs is given like the title of
+// a migration file.
+func RegisterNamedGoMigration(version uint, title string, up, down GoMigrationFunc) {
+	goMigrationsMu.Lock()
+	defer goMigrationsMu.Unlock()
+
+	if !migrationNameRegex.MatchString(title) {
+		panic(fmt.Sprintf("go migration %d title %s doesnt match the regex: %v", version, title, migrationNameRegex.String()))
+	}
+
+	if up == nil && down == nil {
+		panic(fmt.Sprintf("go migration %d has neither up nor down function", version))
+	}
+
+	if _, dup := goMigrations[version]; dup {
+		panic(fmt.Sprintf("go migration %d is already registered", version))
+	}
+
+	goMigrations[version] = goMigration{title: title, up: up, down: down}
+}
+
+// registeredGoMigrations returns a copy of registered Go migrations with
+// the versions converted to go-migrate format.
+func registeredGoMigrations(format MigrationFormat) (map[uint]goMigration, error) {
+	goMigrationsMu.RLock()
+	defer goMigrationsMu.RUnlock()
+
+	res := make(map[uint]goMigration, len(goMigrations))
+	for version, m := range goMigrations {
+		if format == MigrationFormatFlyway {
+			v, err := flywayVersionToGoMigrate(strconv.FormatUint(uint64(version), 10))
+			if err != nil {
+				return nil, err
+			}
+			version = v
+		}
+
+		res[version] = m
+	}
+
+	return res, nil
+}
+
+// lookupGoMigration returns the Go migration function for the given
+// marker body produced by goMigrationSource.
+func lookupGoMigration(migrations map[uint]goMigration, body string) (GoMigrationFunc, error) {
+	fields := strings.Fields(strings.TrimPrefix(body, goMigrationMarker))
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("invalid go migration marker: %s", body)
+	}
+
+	version, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid go migration version %s: %w", fields[1], err)
+	}
+
+	m, ok := migrations[uint(version)]
+	if !ok {
+		return nil, fmt.Errorf("go migration %d is not registered", version)
+	}
+
+	if fields[0] == "down" {
+		return m.down, nil
+	}
+
+	return m.up, nil
+}
+
+// goMigrationSource is a source.Driver that merges registered Go migrations
+// with the migration files provided by the underlying source.
+type goMigrationSource struct {
+	source.Driver
+	migrations map[uint]goMigration
+	versions   []uint
+}
+
+func newGoMigrationSource(src source.Driver, migrations map[uint]goMigration) (*goMigrationSource, error) {
+	var versions []uint
+
+	version, err := src.First()
+	for err == nil {
+		if _, ok := migrations[version]; ok {
+			return nil, fmt.Errorf("go migration %d conflicts with a migration file of the same version", version)
+		}
+		versions = append(versions, version)
+		version, err = src.Next(version)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for version := range migrations {
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	return &goMigrationSource{
+		Driver:     src,
+		migrations: migrations,
+		versions:   versions,
+	}, nil
+}
+
+func (s *goMigrationSource) First() (uint, error) {
+	if len(s.versions) == 0 {
+		return 0, os.ErrNotExist
+	}
+
+	return s.versions[0], nil
+}
+
+func (s *goMigrationSource) Prev(version uint) (uint, error) {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] >= version })
+	if i == 0 {
+		return 0, os.ErrNotExist
+	}
+
+	return s.versions[i-1], nil
+}
+
+func (s *goMigrationSource) Next(version uint) (uint, error) {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] > version })
+	if i >= len(s.versions) {
+		return 0, os.ErrNotExist
+	}
+
+	return s.versions[i], nil
+}
+
+func (s *goMigrationSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	if m, ok := s.migrations[version]; ok {
+		return readGoMigration(m.title, m.up, "up", version)
+	}
+
+	return s.Driver.ReadUp(version)
+}
+
+func (s *goMigrationSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	if m, ok := s.migrations[version]; ok {
+		return readGoMigration(m.title, m.down, "down", version)
+	}
+
+	return s.Driver.ReadDown(version)
+}
+
+// readGoMigration returns the marker body of the Go migration and its
+// title as the identifier.
+func readGoMigration(title string, fn GoMigrationFunc, direction string, version uint) (io.ReadCloser, string, error) {
+	if fn == nil {
+		return nil, "", os.ErrNotExist
+	}
+
+	body := fmt.Sprintf("%s %s %d", goMigrationMarker, direction, version)
+
+	return io.NopCloser(strings.NewReader(body)), title, nil
+}
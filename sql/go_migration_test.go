@@ -0,0 +1,222 @@
+package pkgsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func noopGoMigration(context.Context, Tx) error { return nil }
+
+func TestRegisterGoMigration(t *testing.T) {
+	const version = 19700101000001
+
+	RegisterGoMigration(version, noopGoMigration, nil)
+	t.Cleanup(func() {
+		goMigrationsMu.Lock()
+		delete(goMigrations, version)
+		goMigrationsMu.Unlock()
+	})
+
+	assert.Equal(t, "go_migration_19700101000001", goMigrations[version].title)
+
+	assert.Panics(t, func() { RegisterGoMigration(version, noopGoMigration, noopGoMigration) })
+	assert.Panics(t, func() { RegisterNamedGoMigration(version, "backfill_users", noopGoMigration, nil) })
+	assert.Panics(t, func() { RegisterGoMigration(version+1, nil, nil) })
+	assert.Panics(t, func() { RegisterNamedGoMigration(version+1, "backfill items", noopGoMigration, nil) })
+
+	issues, err := LintMigrations(MigratorConfig{MigrationsFs: fstest.MapFS{}, Format: MigrationFormatFlyway}, LintOptions{})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, LintRuleMissingDownMigration, issues[0].Rule)
+	assert.Equal(t, uint(version), issues[0].Version)
+}
+
+func TestGoMigrationSource(t *testing.T) {
+	t.Parallel()
+
+	files := fstest.MapFS{
+		"20230101000000_users.up.sql":   {Data: []byte(`CREATE TABLE users (id bigint);`)},
+		"20230101000000_users.down.sql": {Data: []byte(`DROP TABLE users;`)},
+		"20230301000000_items.up.sql":   {Data: []byte(`CREATE TABLE items (id bigint);`)},
+	}
+
+	newSource := func(t *testing.T, migrations map[uint]goMigration) (*goMigrationSource, error) {
+		t.Helper()
+
+		src, err := iofs.New(files, ".")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = src.Close() })
+
+		return newGoMigrationSource(src, migrations)
+	}
+
+	t.Run("order", func(t *testing.T) {
+		src, err := newSource(t, map[uint]goMigration{
+			20230201000000: {title: "backfill_users", up: noopGoMigration},
+			20230401000000: {title: "backfill_items", up: noopGoMigration, down: noopGoMigration},
+		})
+		require.NoError(t, err)
+
+		var versions []uint
+		version, err := src.First()
+		for err == nil {
+			versions = append(versions, version)
+			version, err = src.Next(version)
+		}
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		assert.Equal(t, []uint{20230101000000, 20230201000000, 20230301000000, 20230401000000}, versions)
+
+		prev, err := src.Prev(20230301000000)
+		require.NoError(t, err)
+		assert.Equal(t, uint(20230201000000), prev)
+
+		r, identifier, err := src.ReadUp(20230201000000)
+		require.NoError(t, err)
+		body, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, "backfill_users", identifier)
+		assert.Equal(t, "-- +migrate Go up 20230201000000", string(body))
+
+		// The Go migration has no down function.
+		_, _, err = src.ReadDown(20230201000000)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+
+		r, identifier, err = src.ReadUp(20230301000000)
+		require.NoError(t, err)
+		_ = r.Close()
+		assert.Equal(t, "items", identifier)
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := newSource(t, map[uint]goMigration{20230301000000: {up: noopGoMigration}})
+		assert.ErrorContains(t, err, "conflicts")
+	})
+}
+
+func TestMigrationDriver_Run(t *testing.T) {
+	t.Parallel()
+
+	errMigration := errors.New("migration failed")
+
+	conn := &recordingConn{}
+	db := sql.OpenDB(recordingConnector{conn: conn})
+	t.Cleanup(func() { _ = db.Close() })
+
+	d := &migrationDriver{
+		db: db,
+		migrations: map[uint]goMigration{
+			20230101000000: {up: func(ctx context.Context, tx Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO users (id) VALUES (1)")
+
+				return err
+			}},
+			20230201000000: {up: func(context.Context, Tx) error { return errMigration }},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		migration string
+		err       error
+		log       []string
+	}{
+		{
+			name:      "go migration",
+			migration: "-- +migrate Go up 20230101000000",
+			log:       []string{"BEGIN", "INSERT INTO users (id) VALUES (1)", "COMMIT"},
+		},
+		{
+			name:      "failed go migration",
+			migration: "-- +migrate Go up 20230201000000",
+			err:       errMigration,
+			log:       []string{"BEGIN", "ROLLBACK"},
+		},
+		{
+			name:      "statements in transaction",
+			migration: "-- +migrate StatementBegin\nSELECT 1; SELECT 2;\n-- +migrate StatementEnd\nSELECT 3;",
+			log:       []string{"BEGIN", "SELECT 1; SELECT 2;", "SELECT 3", "COMMIT"},
+		},
+		{
+			name:      "no transaction",
+			migration: "-- +migrate NoTransaction\nCREATE INDEX CONCURRENTLY users_idx ON users (id);\nVACUUM users;",
+			log:       []string{"CREATE INDEX CONCURRENTLY users_idx ON users (id)", "VACUUM users"},
+		},
+	}
+
+	for _, tt := range tests {
+		conn.log = nil
+
+		err := d.Run(strings.NewReader(tt.migration))
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.name)
+		} else {
+			assert.NoError(t, err, tt.name)
+		}
+		assert.Equal(t, tt.log, conn.log, tt.name)
+	}
+}
+
+// recordingConn is a driver.Conn that records the executed statements
+// and the transaction boundaries.
+type recordingConn struct {
+	log []string
+}
+
+func (c *recordingConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare is not supported")
+}
+
+func (c *recordingConn) Close() error {
+	return nil
+}
+
+func (c *recordingConn) Begin() (driver.Tx, error) {
+	c.log = append(c.log, "BEGIN")
+
+	return recordingTx{conn: c}, nil
+}
+
+func (c *recordingConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.log = append(c.log, query)
+
+	return driver.RowsAffected(0), nil
+}
+
+type recordingTx struct {
+	conn *recordingConn
+}
+
+func (tx recordingTx) Commit() error {
+	tx.conn.log = append(tx.conn.log, "COMMIT")
+
+	return nil
+}
+
+func (tx recordingTx) Rollback() error {
+	tx.conn.log = append(tx.conn.log, "ROLLBACK")
+
+	return nil
+}
+
+type recordingConnector struct {
+	conn *recordingConn
+}
+
+func (c recordingConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.conn, nil
+}
+
+func (c recordingConnector) Driver() driver.Driver {
+	return nil
+}
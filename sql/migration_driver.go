@@ -0,0 +1,136 @@
+package pkgsql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	nurl "net/url"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
+)
+
+// migrationDriver is a database.Driver that wraps the go-migrate Postgres
+// driver and executes Go migrations in a transaction.
+//
+// The go-migrate driver doesn't expose its connection, so the migrations
+// that are not passed to it run on a separate pool limited to a single
+// connection. The pool is closed together with the driver. database.Driver
+// has no context, so the migrations run with context.Background.
+type migrationDriver struct {
+	database.Driver
+	db         *sql.DB
+	migrations map[uint]goMigration
+}
+
+func newMigrationDriver(dsn string, migrations map[uint]goMigration) (*migrationDriver, error) {
+	drv, err := database.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := nurl.Parse(dsn)
+	if err != nil {
+		_ = drv.Close()
+
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", migrate.FilterCustomQuery(u).String())
+	if err != nil {
+		_ = drv.Close()
+
+		return nil, err
+	}
+	// The migrations run one at a time.
+	db.SetMaxOpenConns(1)
+
+	return &migrationDriver{
+		Driver:     drv,
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Run runs a migration. Go migrations are executed inside a transaction.
+// SQL migrations with directives are split into statements and executed
+// either in a transaction or one by one if DirectiveNoTransaction is set.
+// Other SQL migrations are passed to the underlying driver as is.
+func (d *migrationDriver) Run(migration io.Reader) error {
+	body, err := io.ReadAll(migration)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	if strings.HasPrefix(string(body), goMigrationMarker) {
+		fn, err := lookupGoMigration(d.migrations, string(body))
+		if err != nil {
+			return err
+		}
+
+		return d.runInTx(ctx, fn)
+	}
+
+	script, err := parseMigrationScript(string(body))
+	if err != nil {
+		return err
+	}
+
+	if !script.HasDirectives {
+		return d.Driver.Run(bytes.NewReader(body))
+	}
+
+	if script.NoTransaction {
+		return d.runWithoutTx(ctx, script.Statements)
+	}
+
+	return d.runInTx(ctx, func(ctx context.Context, tx Tx) error {
+		return execStatements(ctx, tx, script.Statements)
+	})
+}
+
+// runWithoutTx executes the statements one by one on a single connection.
+func (d *migrationDriver) runWithoutTx(ctx context.Context, statements []string) error {
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	return execStatements(ctx, conn, statements)
+}
+
+func (d *migrationDriver) runInTx(ctx context.Context, fn GoMigrationFunc) (err error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	defer func() {
+		err = EndTx(tx, err)
+	}()
+
+	return fn(ctx, tx)
+}
+
+func execStatements(ctx context.Context, execer Execer, statements []string) error {
+	for _, stmt := range statements {
+		_, err := execer.ExecContext(ctx, stmt)
+		if err != nil {
+			return fmt.Errorf("failed to execute statement %q: %w", stmt, err)
+		}
+	}
+
+	return nil
+}
+
+// Close closes both the underlying driver and the database connection.
+func (d *migrationDriver) Close() error {
+	return errors.Join(d.Driver.Close(), d.db.Close())
+}
@@ -0,0 +1,224 @@
+package pkgsql
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Regex to match the go-migrate migration file format.
+//
+// Example:
+// * 20230504225253_cdr_tables.up.sql
+// * 20230504225253_cdr_tables.down.sql
+var goMigrateFileFormatRegex = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Regex to validate the name of a new migration.
+var migrationNameRegex = regexp.MustCompile(`^\w+$`)
+
+// migrationFile is a single migration file read from the migrations dir.
+type migrationFile struct {
+	// Path is a path of the file relative to the migrations dir.
+	Path string
+	// Version is a version as it is written in the file name.
+	Version uint
+	Title   string
+	Up      bool
+	Body    []byte
+}
+
+// MigrationFileNames returns the names of up and down migration files
+// for a migration with the given name created at the given time.
+//
+// Example:
+//
+//	flyway:    V1683211973__cdr_tables.sql, U1683211973__cdr_tables.sql
+//	gomigrate: 20230504225253_cdr_tables.up.sql, 20230504225253_cdr_tables.down.sql
+func MigrationFileNames(format MigrationFormat, createdAt time.Time, name string) (up string, down string, err error) {
+	if !migrationNameRegex.MatchString(name) {
+		return "", "", fmt.Errorf("migration name %s doesnt match the regex: %v", name, migrationNameRegex.String())
+	}
+
+	switch format {
+	case MigrationFormatFlyway:
+		version := createdAt.Unix()
+
+		return fmt.Sprintf("V%d__%s.sql", version, name), fmt.Sprintf("U%d__%s.sql", version, name), nil
+	case MigrationFormatGomigrate:
+		version := createdAt.Format(goMigrateTimeFormat)
+
+		return fmt.Sprintf("%s_%s.up.sql", version, name), fmt.Sprintf("%s_%s.down.sql", version, name), nil
+	default:
+		return "", "", errors.New("unknown migration file format")
+	}
+}
+
+// ConvertMigrationFileName converts SQL migration file name from one format to another.
+func ConvertMigrationFileName(name string, from, to MigrationFormat) (string, error) {
+	if from == to {
+		return name, nil
+	}
+
+	switch {
+	case from == MigrationFormatFlyway && to == MigrationFormatGomigrate:
+		return flywayFormatToGoMigrate(name)
+	case from == MigrationFormatGomigrate && to == MigrationFormatFlyway:
+		return goMigrateFormatToFlyway(name)
+	default:
+		return "", fmt.Errorf("conversion from %s to %s is not supported", from, to)
+	}
+}
+
+// goMigrateFormatToFlyway converts SQL migration file name from
+// go-migrate format to Flyway format.
+// It is the reverse of flywayFormatToGoMigrate.
+//
+// Example:
+//
+//	20230504225253_tables.up.sql -> V1683211973__tables.sql
+func goMigrateFormatToFlyway(name string) (string, error) {
+	match := goMigrateFileFormatRegex.FindStringSubmatch(name)
+	if len(match) < 4 {
+		return "", fmt.Errorf("file %s doesnt match the regex: %v", name, goMigrateFileFormatRegex.String())
+	}
+
+	version, err := goMigrateVersionToFlyway(match[1])
+	if err != nil {
+		return "", err
+	}
+
+	prefix := "V"
+	if match[3] == "down" {
+		prefix = "U"
+	}
+
+	return fmt.Sprintf("%s%d__%s.sql", prefix, version, match[2]), nil
+}
+
+// goMigrateVersionToFlyway converts go-migrate version in format
+// 20060102150405 to unix seconds used by Flyway migrations.
+func goMigrateVersionToFlyway(version string) (int64, error) {
+	t, err := time.ParseInLocation(goMigrateTimeFormat, version, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse version %s as time: %w", version, err)
+	}
+
+	return t.Unix(), nil
+}
+
+// flywayVersionToGoMigrate converts Flyway version in unix seconds
+// to go-migrate version in format 20060102150405.
+func flywayVersionToGoMigrate(version string) (uint, error) {
+	intVersion, err := strconv.ParseInt(version, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to convert %s to unix seconds: %w", version, err)
+	}
+
+	formattedVersion := time.Unix(intVersion, 0).Format(goMigrateTimeFormat)
+
+	v, err := strconv.ParseUint(formattedVersion, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(v), nil
+}
+
+// parseMigrationFileName parses the version, title and direction from
+// the migration file name in the given format.
+func parseMigrationFileName(format MigrationFormat, name string) (version uint, title string, up bool, err error) {
+	var versionStr string
+
+	switch format {
+	case MigrationFormatFlyway:
+		match := flywayFileFormatRegex.FindStringSubmatch(name)
+		if len(match) < 4 {
+			return 0, "", false, fmt.Errorf("file %s doesnt match the regex: %v", name, flywayFileFormatRegex.String())
+		}
+		versionStr, title, up = match[2], match[3], match[1] == "V"
+	case MigrationFormatGomigrate:
+		match := goMigrateFileFormatRegex.FindStringSubmatch(name)
+		if len(match) < 4 {
+			return 0, "", false, fmt.Errorf("file %s doesnt match the regex: %v", name, goMigrateFileFormatRegex.String())
+		}
+		versionStr, title, up = match[1], match[2], match[3] == "up"
+	default:
+		return 0, "", false, errors.New("unknown migration file format")
+	}
+
+	v, err := strconv.ParseUint(versionStr, 10, 64)
+	if err != nil {
+		return 0, "", false, fmt.Errorf("invalid version %s: %w", versionStr, err)
+	}
+
+	return uint(v), title, up, nil
+}
+
+// migrationsFS returns the file system with the migration files configured in cfg.
+func migrationsFS(cfg MigratorConfig) (fs.FS, error) {
+	if cfg.MigrationsFs == nil {
+		return os.DirFS(cfg.MigrationsDir), nil
+	}
+
+	if cfg.MigrationsDir == "" || cfg.MigrationsDir == "." {
+		return cfg.MigrationsFs, nil
+	}
+
+	return fs.Sub(cfg.MigrationsFs, cfg.MigrationsDir)
+}
+
+// readMigrationFiles reads all the migration files configured in cfg.
+// Files that don't match the configured format are returned separately.
+// Flyway migrations are read recursively, go-migrate migrations only
+// from the top level dir, the same way Migrator reads them.
+func readMigrationFiles(cfg MigratorConfig) (files []migrationFile, invalid []string, err error) {
+	fsys, err := migrationsFS(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != "." && cfg.Format == MigrationFormatGomigrate {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		version, title, up, err := parseMigrationFileName(cfg.Format, d.Name())
+		if err != nil {
+			invalid = append(invalid, path)
+
+			return nil
+		}
+
+		body, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, migrationFile{
+			Path:    path,
+			Version: version,
+			Title:   title,
+			Up:      up,
+			Body:    body,
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return files, invalid, nil
+}
@@ -0,0 +1,52 @@
+package pkgsql_test
+
+import (
+	"testing"
+	"time"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrationFileNames(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Unix(1683211973, 0)
+
+	t.Run("flyway", func(t *testing.T) {
+		up, down, err := pkgsql.MigrationFileNames(pkgsql.MigrationFormatFlyway, createdAt, "cdr_tables")
+		require.NoError(t, err)
+		assert.Equal(t, "V1683211973__cdr_tables.sql", up)
+		assert.Equal(t, "U1683211973__cdr_tables.sql", down)
+	})
+
+	t.Run("gomigrate", func(t *testing.T) {
+		up, down, err := pkgsql.MigrationFileNames(pkgsql.MigrationFormatGomigrate, createdAt, "cdr_tables")
+		require.NoError(t, err)
+		version := createdAt.Format("20060102150405")
+		assert.Equal(t, version+"_cdr_tables.up.sql", up)
+		assert.Equal(t, version+"_cdr_tables.down.sql", down)
+	})
+
+	t.Run("invalid name", func(t *testing.T) {
+		_, _, err := pkgsql.MigrationFileNames(pkgsql.MigrationFormatFlyway, createdAt, "cdr tables")
+		assert.Error(t, err)
+	})
+}
+
+func TestConvertMigrationFileName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"V1683211973__cdr_tables.sql", "U1683211973__cdr_tables.sql"} {
+		goMigrateName, err := pkgsql.ConvertMigrationFileName(name, pkgsql.MigrationFormatFlyway, pkgsql.MigrationFormatGomigrate)
+		require.NoError(t, err)
+
+		flywayName, err := pkgsql.ConvertMigrationFileName(goMigrateName, pkgsql.MigrationFormatGomigrate, pkgsql.MigrationFormatFlyway)
+		require.NoError(t, err)
+		assert.Equal(t, name, flywayName)
+	}
+
+	_, err := pkgsql.ConvertMigrationFileName("cdr_tables.sql", pkgsql.MigrationFormatGomigrate, pkgsql.MigrationFormatFlyway)
+	assert.Error(t, err)
+}
@@ -0,0 +1,424 @@
+package pkgsql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LintRule is a name of the rule checked by LintMigrations.
+type LintRule string
+
+const (
+	// LintRuleInvalidFileName reports files that don't match the migration format.
+	LintRuleInvalidFileName LintRule = "invalid-file-name"
+	// LintRuleDuplicateVersion reports several migrations with the same version.
+	LintRuleDuplicateVersion LintRule = "duplicate-version"
+	// LintRuleNonMonotonicVersion reports versions that are not valid timestamps
+	// or are in the future.
+	LintRuleNonMonotonicVersion LintRule = "non-monotonic-version"
+	// LintRuleMissingDownMigration reports up migrations, including registered
+	// Go migrations, without a down migration.
+	LintRuleMissingDownMigration LintRule = "missing-down-migration"
+	// LintRuleMissingUpMigration reports down migrations without an up migration.
+	LintRuleMissingUpMigration LintRule = "missing-up-migration"
+	// LintRuleInvalidDirective reports malformed or unknown migration directives.
+	LintRuleInvalidDirective LintRule = "invalid-directive"
+	// LintRuleNoTransactionRequired reports statements that cannot run inside
+	// a transaction in migrations without the NoTransaction directive.
+	LintRuleNoTransactionRequired LintRule = "no-transaction-required"
+	// LintRuleCreateIndexNonConcurrently reports CREATE INDEX without CONCURRENTLY
+	// on existing tables, which blocks writes for the whole build.
+	LintRuleCreateIndexNonConcurrently LintRule = "create-index-non-concurrently"
+	// LintRuleDropIndexNonConcurrently reports DROP INDEX without CONCURRENTLY.
+	LintRuleDropIndexNonConcurrently LintRule = "drop-index-non-concurrently"
+	// LintRuleAddColumnWithDefault reports ADD COLUMN ... DEFAULT on existing tables.
+	// A volatile default rewrites the whole table under an exclusive lock.
+	LintRuleAddColumnWithDefault LintRule = "add-column-with-default"
+	// LintRuleAddColumnNotNull reports ADD COLUMN ... NOT NULL without a default,
+	// which fails on non-empty tables.
+	LintRuleAddColumnNotNull LintRule = "add-column-not-null"
+	// LintRuleAlterColumnType reports ALTER COLUMN ... TYPE, which rewrites the table.
+	LintRuleAlterColumnType LintRule = "alter-column-type"
+	// LintRuleSetNotNull reports ALTER COLUMN ... SET NOT NULL, which scans
+	// the whole table under an exclusive lock.
+	LintRuleSetNotNull LintRule = "set-not-null"
+	// LintRuleAddConstraintWithoutNotValid reports foreign key and check
+	// constraints added without NOT VALID.
+	LintRuleAddConstraintWithoutNotValid LintRule = "add-constraint-without-not-valid"
+	// LintRuleAddUniqueConstraint reports unique and primary key constraints
+	// added without USING INDEX, which builds the index under an exclusive lock.
+	LintRuleAddUniqueConstraint LintRule = "add-unique-constraint"
+)
+
+// LintSeverity is a severity of the LintIssue.
+type LintSeverity string
+
+const (
+	LintSeverityError   LintSeverity = "error"
+	LintSeverityWarning LintSeverity = "warning"
+)
+
+var lintRuleSeverity = map[LintRule]LintSeverity{
+	LintRuleInvalidFileName:              LintSeverityError,
+	LintRuleDuplicateVersion:             LintSeverityError,
+	LintRuleNonMonotonicVersion:          LintSeverityError,
+	LintRuleMissingDownMigration:         LintSeverityWarning,
+	LintRuleMissingUpMigration:           LintSeverityError,
+	LintRuleInvalidDirective:             LintSeverityError,
+	LintRuleNoTransactionRequired:        LintSeverityError,
+	LintRuleCreateIndexNonConcurrently:   LintSeverityError,
+	LintRuleDropIndexNonConcurrently:     LintSeverityWarning,
+	LintRuleAddColumnWithDefault:         LintSeverityWarning,
+	LintRuleAddColumnNotNull:             LintSeverityError,
+	LintRuleAlterColumnType:              LintSeverityError,
+	LintRuleSetNotNull:                   LintSeverityWarning,
+	LintRuleAddConstraintWithoutNotValid: LintSeverityError,
+	LintRuleAddUniqueConstraint:          LintSeverityWarning,
+}
+
+var (
+	createTableRegex   = regexp.MustCompile(`(?i)^CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY|UNLOGGED)\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?([\w."]+)`)
+	createIndexRegex   = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:[\w."]+\s+)?ON\s+(?:ONLY\s+)?([\w."]+)`)
+	dropIndexRegex     = regexp.MustCompile(`(?i)^DROP\s+INDEX\s+(CONCURRENTLY\s+)?`)
+	alterTableRegex    = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?([\w."]+)\s+(.*)$`)
+	addColumnRegex     = regexp.MustCompile(`(?i)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?([\w"]+)\s`)
+	defaultRegex       = regexp.MustCompile(`(?i)\bDEFAULT\b`)
+	notNullRegex       = regexp.MustCompile(`(?i)\bNOT\s+NULL\b`)
+	alterTypeRegex     = regexp.MustCompile(`(?i)^ALTER\s+(?:COLUMN\s+)?[\w"]+\s+(?:SET\s+DATA\s+)?TYPE\b`)
+	setNotNullRegex    = regexp.MustCompile(`(?i)^ALTER\s+(?:COLUMN\s+)?[\w"]+\s+SET\s+NOT\s+NULL\b`)
+	addConstraintRegex = regexp.MustCompile(`(?i)^ADD\s+(?:CONSTRAINT\s+[\w"]+\s+)?(FOREIGN\s+KEY|CHECK|UNIQUE|PRIMARY\s+KEY)\b`)
+	notValidRegex      = regexp.MustCompile(`(?i)\bNOT\s+VALID\b`)
+	usingIndexRegex    = regexp.MustCompile(`(?i)\bUSING\s+INDEX\b`)
+	noTransactionRegex = regexp.MustCompile(`(?i)^(?:(?:CREATE|DROP|REINDEX|REFRESH)\b.*\bCONCURRENTLY\b|VACUUM\b|CREATE\s+DATABASE\b|DROP\s+DATABASE\b)`)
+)
+
+// constraintKeywords are the words that can follow ADD in ALTER TABLE
+// and denote a constraint rather than a column name.
+var constraintKeywords = map[string]struct{}{
+	"constraint": {},
+	"primary":    {},
+	"unique":     {},
+	"foreign":    {},
+	"check":      {},
+	"exclude":    {},
+}
+
+// LintIssue is a problem found in the migration files.
+type LintIssue struct {
+	Path     string
+	Version  uint
+	Rule     LintRule
+	Severity LintSeverity
+	Message  string
+}
+
+// String implements the Stringer interface.
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s: %s: [%s] %s", i.Path, i.Severity, i.Rule, i.Message)
+}
+
+// LintOptions configures LintMigrations.
+type LintOptions struct {
+	// DisabledRules are the rules that are not checked.
+	DisabledRules []LintRule
+	// Now returns the current time used to detect versions in the future.
+	// Defaults to time.Now.
+	Now func() time.Time
+}
+
+// HasLintErrors reports whether any of the issues has error severity.
+func HasLintErrors(issues []LintIssue) bool {
+	for _, issue := range issues {
+		if issue.Severity == LintSeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Lint checks the migrations of the Migrator. See LintMigrations.
+func (m *Migrator) Lint(opts LintOptions) ([]LintIssue, error) {
+	return LintMigrations(m.cfg, opts)
+}
+
+// LintMigrations checks the migration files configured in cfg
+// and registered Go migrations for common mistakes: invalid file names,
+// duplicate and non-monotonic versions, missing down migrations and
+// DDL statements that take long blocking locks on existing tables.
+// DSN is not required, so the function can be run in CI.
+func LintMigrations(cfg MigratorConfig, opts LintOptions) ([]LintIssue, error) {
+	files, invalid, err := readMigrationFiles(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration files: %w", err)
+	}
+
+	l := linter{
+		disabled: make(map[LintRule]struct{}, len(opts.DisabledRules)),
+		now:      time.Now,
+	}
+	if opts.Now != nil {
+		l.now = opts.Now
+	}
+	for _, rule := range opts.DisabledRules {
+		l.disabled[rule] = struct{}{}
+	}
+
+	for _, path := range invalid {
+		l.report(path, 0, LintRuleInvalidFileName, fmt.Sprintf("file doesnt match the %s migration format", cfg.Format))
+	}
+
+	// The migrations are applied in the numeric order of the versions,
+	// e.g. V999 before V1000, which is not the order of the file names.
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Version != files[j].Version {
+			return files[i].Version < files[j].Version
+		}
+
+		return files[i].Path < files[j].Path
+	})
+
+	l.lintVersions(cfg.Format, files)
+
+	for _, f := range files {
+		l.lintScript(f)
+	}
+
+	sort.SliceStable(l.issues, func(i, j int) bool { return l.issues[i].Path < l.issues[j].Path })
+
+	return l.issues, nil
+}
+
+type linter struct {
+	disabled map[LintRule]struct{}
+	now      func() time.Time
+	issues   []LintIssue
+}
+
+func (l *linter) report(path string, version uint, rule LintRule, msg string) {
+	if _, ok := l.disabled[rule]; ok {
+		return
+	}
+
+	l.issues = append(l.issues, LintIssue{
+		Path:     path,
+		Version:  version,
+		Rule:     rule,
+		Severity: lintRuleSeverity[rule],
+		Message:  msg,
+	})
+}
+
+func (l *linter) lintVersions(format MigrationFormat, files []migrationFile) {
+	goMigrationsMu.RLock()
+	goVersions := make(map[uint]struct{}, len(goMigrations))
+	for version, m := range goMigrations {
+		goVersions[version] = struct{}{}
+		if m.up != nil && m.down == nil {
+			l.report(fmt.Sprintf("go migration %d", version), version, LintRuleMissingDownMigration, "go migration has no down function")
+		}
+	}
+	goMigrationsMu.RUnlock()
+
+	ups := make(map[uint]migrationFile)
+	downs := make(map[uint]migrationFile)
+
+	for _, f := range files {
+		byDirection := downs
+		if f.Up {
+			byDirection = ups
+		}
+
+		if dup, ok := byDirection[f.Version]; ok {
+			l.report(f.Path, f.Version, LintRuleDuplicateVersion, fmt.Sprintf("version %d is already used by %s", f.Version, dup.Path))
+		} else {
+			byDirection[f.Version] = f
+		}
+
+		if _, ok := goVersions[f.Version]; ok && f.Up {
+			l.report(f.Path, f.Version, LintRuleDuplicateVersion, fmt.Sprintf("version %d is already used by a go migration", f.Version))
+		}
+
+		if !f.Up {
+			continue
+		}
+
+		createdAt, err := migrationVersionTime(format, f.Version)
+		switch {
+		case err != nil:
+			l.report(f.Path, f.Version, LintRuleNonMonotonicVersion, err.Error())
+		case createdAt.After(l.now()):
+			l.report(f.Path, f.Version, LintRuleNonMonotonicVersion, fmt.Sprintf("version %d is in the future", f.Version))
+		}
+	}
+
+	for version, f := range ups {
+		if _, ok := downs[version]; !ok {
+			l.report(f.Path, version, LintRuleMissingDownMigration, "up migration has no down migration")
+		}
+	}
+
+	for version, f := range downs {
+		if _, ok := ups[version]; !ok {
+			l.report(f.Path, version, LintRuleMissingUpMigration, "down migration has no up migration")
+		}
+	}
+}
+
+func (l *linter) lintScript(f migrationFile) {
+	script, err := parseMigrationScript(string(f.Body))
+	if err != nil {
+		l.report(f.Path, f.Version, LintRuleInvalidDirective, err.Error())
+
+		return
+	}
+
+	statements := make([]string, 0, len(script.Statements))
+	for _, stmt := range script.Statements {
+		statements = append(statements, normalizeSQL(stmt))
+	}
+
+	if !script.NoTransaction {
+		for _, stmt := range statements {
+			if noTransactionRegex.MatchString(stmt) {
+				l.report(f.Path, f.Version, LintRuleNoTransactionRequired, fmt.Sprintf("statement cannot run inside a transaction, add %q directive: %s", migrationDirectivePrefix+DirectiveNoTransaction, stmt))
+			}
+		}
+	}
+
+	if f.Up {
+		l.lintStatements(f, statements)
+	}
+}
+
+func (l *linter) lintStatements(f migrationFile, statements []string) {
+	newTables := make(map[string]struct{})
+	for _, stmt := range statements {
+		if match := createTableRegex.FindStringSubmatch(stmt); match != nil {
+			newTables[normalizeIdentifier(match[1])] = struct{}{}
+		}
+	}
+
+	isNewTable := func(name string) bool {
+		_, ok := newTables[normalizeIdentifier(name)]
+
+		return ok
+	}
+
+	for _, stmt := range statements {
+		if match := createIndexRegex.FindStringSubmatch(stmt); match != nil {
+			if match[1] == "" && !isNewTable(match[2]) {
+				l.report(f.Path, f.Version, LintRuleCreateIndexNonConcurrently, fmt.Sprintf("use CREATE INDEX CONCURRENTLY on existing table %s", match[2]))
+			}
+
+			continue
+		}
+
+		if match := dropIndexRegex.FindStringSubmatch(stmt); match != nil {
+			if match[1] == "" {
+				l.report(f.Path, f.Version, LintRuleDropIndexNonConcurrently, "use DROP INDEX CONCURRENTLY")
+			}
+
+			continue
+		}
+
+		if match := alterTableRegex.FindStringSubmatch(stmt); match != nil && !isNewTable(match[1]) {
+			for _, action := range splitTopLevel(match[2], ',') {
+				l.lintAlterTableAction(f, match[1], strings.TrimSpace(action))
+			}
+		}
+	}
+}
+
+func (l *linter) lintAlterTableAction(f migrationFile, table, action string) {
+	if match := addColumnRegex.FindStringSubmatch(action); match != nil {
+		if _, ok := constraintKeywords[strings.ToLower(match[1])]; !ok {
+			hasDefault := defaultRegex.MatchString(action)
+			if hasDefault {
+				l.report(f.Path, f.Version, LintRuleAddColumnWithDefault, fmt.Sprintf("column %s is added to existing table %s with a default value", match[1], table))
+			}
+
+			if !hasDefault && notNullRegex.MatchString(action) {
+				l.report(f.Path, f.Version, LintRuleAddColumnNotNull, fmt.Sprintf("NOT NULL column %s without a default is added to existing table %s", match[1], table))
+			}
+
+			return
+		}
+	}
+
+	if alterTypeRegex.MatchString(action) {
+		l.report(f.Path, f.Version, LintRuleAlterColumnType, fmt.Sprintf("changing column type rewrites table %s", table))
+
+		return
+	}
+
+	if setNotNullRegex.MatchString(action) {
+		l.report(f.Path, f.Version, LintRuleSetNotNull, fmt.Sprintf("SET NOT NULL scans table %s, validate a CHECK constraint first", table))
+
+		return
+	}
+
+	if match := addConstraintRegex.FindStringSubmatch(action); match != nil {
+		kind := strings.ToUpper(whitespaceRegex.ReplaceAllString(match[1], " "))
+
+		switch kind {
+		case "FOREIGN KEY", "CHECK":
+			if !notValidRegex.MatchString(action) {
+				l.report(f.Path, f.Version, LintRuleAddConstraintWithoutNotValid, fmt.Sprintf("add %s constraint to table %s as NOT VALID and validate it separately", kind, table))
+			}
+		default:
+			if !usingIndexRegex.MatchString(action) {
+				l.report(f.Path, f.Version, LintRuleAddUniqueConstraint, fmt.Sprintf("create the index for %s constraint on table %s concurrently and add it with USING INDEX", kind, table))
+			}
+		}
+	}
+}
+
+// migrationVersionTime returns the time the migration was created at
+// based on its version.
+func migrationVersionTime(format MigrationFormat, version uint) (time.Time, error) {
+	if format == MigrationFormatFlyway {
+		return time.Unix(int64(version), 0), nil
+	}
+
+	t, err := time.ParseInLocation(goMigrateTimeFormat, strconv.FormatUint(uint64(version), 10), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("version %d is not a timestamp in format %s", version, goMigrateTimeFormat)
+	}
+
+	return t, nil
+}
+
+// normalizeIdentifier removes quotes from the identifier and lowercases it.
+func normalizeIdentifier(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, `"`, ""))
+}
+
+// splitTopLevel splits s by sep ignoring separators inside parentheses.
+func splitTopLevel(s string, sep rune) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
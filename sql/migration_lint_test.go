@@ -0,0 +1,123 @@
+package pkgsql_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintMigrations(t *testing.T) {
+	t.Parallel()
+
+	lint := func(t *testing.T, files fstest.MapFS) map[pkgsql.LintRule][]string {
+		t.Helper()
+
+		issues, err := pkgsql.LintMigrations(pkgsql.MigratorConfig{
+			MigrationsFs: files,
+			Format:       pkgsql.MigrationFormatFlyway,
+		}, pkgsql.LintOptions{})
+		require.NoError(t, err)
+
+		res := make(map[pkgsql.LintRule][]string)
+		for _, issue := range issues {
+			res[issue.Rule] = append(res[issue.Rule], issue.Path)
+		}
+
+		return res
+	}
+
+	t.Run("new table is not reported", func(t *testing.T) {
+		issues := lint(t, fstest.MapFS{
+			"V1683211973__users.sql": {Data: []byte(`
+				CREATE TABLE users (id bigint PRIMARY KEY, email text NOT NULL);
+				CREATE UNIQUE INDEX users_email_idx ON users (email);
+				ALTER TABLE users ADD COLUMN created_at timestamptz NOT NULL DEFAULT now();
+			`)},
+			"U1683211973__users.sql": {Data: []byte(`DROP TABLE users;`)},
+		})
+		assert.Empty(t, issues)
+	})
+
+	t.Run("blocking DDL on existing table", func(t *testing.T) {
+		issues := lint(t, fstest.MapFS{
+			"V1683211973__users.sql": {Data: []byte(`
+				-- CREATE INDEX CONCURRENTLY is required here
+				CREATE INDEX users_email_idx ON users (email);
+				ALTER TABLE users
+					ADD COLUMN age int NOT NULL,
+					ADD COLUMN status text DEFAULT 'active',
+					ALTER COLUMN email TYPE varchar(255);
+				ALTER TABLE orders ADD CONSTRAINT orders_user_fk FOREIGN KEY (user_id) REFERENCES users (id);
+				ALTER TABLE orders ADD CONSTRAINT orders_total_check CHECK (total > 0) NOT VALID;
+			`)},
+		})
+		assert.Equal(t, map[pkgsql.LintRule][]string{
+			pkgsql.LintRuleMissingDownMigration:         {"V1683211973__users.sql"},
+			pkgsql.LintRuleCreateIndexNonConcurrently:   {"V1683211973__users.sql"},
+			pkgsql.LintRuleAddColumnNotNull:             {"V1683211973__users.sql"},
+			pkgsql.LintRuleAddColumnWithDefault:         {"V1683211973__users.sql"},
+			pkgsql.LintRuleAlterColumnType:              {"V1683211973__users.sql"},
+			pkgsql.LintRuleAddConstraintWithoutNotValid: {"V1683211973__users.sql"},
+		}, issues)
+	})
+
+	t.Run("directives", func(t *testing.T) {
+		issues := lint(t, fstest.MapFS{
+			"V1683211973__users.sql": {Data: []byte(`
+				-- +migrate NoTransaction
+				CREATE INDEX CONCURRENTLY users_email_idx ON users (email);
+
+				-- +migrate StatementBegin
+				CREATE FUNCTION create_index() RETURNS void AS $body$
+				BEGIN
+					EXECUTE 'CREATE INDEX users_name_idx ON users (name); ALTER TABLE users ALTER COLUMN name TYPE text';
+				END;
+				$body$ LANGUAGE plpgsql;
+				-- +migrate StatementEnd
+
+				COMMENT ON TABLE users IS 'CREATE INDEX users_idx ON users (id); -- not a comment';
+			`)},
+			"U1683211973__users.sql": {Data: []byte(`
+				DROP INDEX CONCURRENTLY users_email_idx;
+			`)},
+			"V1683211974__items.sql": {Data: []byte(`
+				-- +migrate StatementBegin
+				SELECT 1;
+			`)},
+			"U1683211974__items.sql": {Data: []byte(`
+				-- +migrate Unknown
+			`)},
+		})
+		assert.Equal(t, map[pkgsql.LintRule][]string{
+			pkgsql.LintRuleNoTransactionRequired: {"U1683211973__users.sql"},
+			pkgsql.LintRuleInvalidDirective:      {"U1683211974__items.sql", "V1683211974__items.sql"},
+		}, issues)
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		issues := lint(t, fstest.MapFS{
+			"V1683211973__users.sql":  {Data: []byte(`SELECT 1;`)},
+			"V1683211973__orders.sql": {Data: []byte(`SELECT 1;`)},
+			"U1683211974__items.sql":  {Data: []byte(`SELECT 1;`)},
+			"V9999999999__future.sql": {Data: []byte(`SELECT 1;`)},
+			"users.sql":               {Data: []byte(`SELECT 1;`)},
+		})
+		assert.Equal(t, []string{"V1683211973__users.sql"}, issues[pkgsql.LintRuleDuplicateVersion])
+		assert.Equal(t, []string{"U1683211974__items.sql"}, issues[pkgsql.LintRuleMissingUpMigration])
+		assert.Equal(t, []string{"V9999999999__future.sql"}, issues[pkgsql.LintRuleNonMonotonicVersion])
+		assert.Equal(t, []string{"users.sql"}, issues[pkgsql.LintRuleInvalidFileName])
+	})
+
+	t.Run("numeric version order", func(t *testing.T) {
+		issues := lint(t, fstest.MapFS{
+			"V999__users.sql":   {Data: []byte(`SELECT 1;`)},
+			"U999__users.sql":   {Data: []byte(`SELECT 1;`)},
+			"V1000__orders.sql": {Data: []byte(`SELECT 1;`)},
+			"U1000__orders.sql": {Data: []byte(`SELECT 1;`)},
+		})
+		assert.Empty(t, issues)
+	})
+}
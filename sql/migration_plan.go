@@ -0,0 +1,161 @@
+package pkgsql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// PlanOutputFormat is a format used by WritePlan.
+type PlanOutputFormat string
+
+const (
+	PlanOutputFormatText PlanOutputFormat = "text"
+	PlanOutputFormatJSON PlanOutputFormat = "json"
+)
+
+// MigrationKind is a kind of the migration.
+type MigrationKind string
+
+const (
+	MigrationKindSQL MigrationKind = "sql"
+	MigrationKindGo  MigrationKind = "go"
+)
+
+// PlannedMigration is a pending up migration that would be applied by MigrateUp.
+type PlannedMigration struct {
+	// Version is a version in the Migrator's format.
+	Version uint          `json:"version"`
+	Title   string        `json:"title"`
+	Kind    MigrationKind `json:"kind"`
+	// SQL is the content of the migration file. It is empty for Go migrations.
+	SQL string `json:"sql,omitempty"`
+}
+
+// Plan returns the up migrations that would be applied by MigrateUp in
+// the order they would be applied. The database is only queried for
+// the current version.
+func (m *Migrator) Plan(ctx context.Context) ([]PlannedMigration, error) {
+	current, dirty, err := m.migrator.Version()
+	hasVersion := true
+	if errors.Is(err, ErrNoVersion) {
+		hasVersion = false
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get current version: %w", err)
+	}
+
+	if dirty {
+		return nil, fmt.Errorf("database is in dirty state at version %d", current)
+	}
+
+	var plan []PlannedMigration
+
+	version, err := m.source.First()
+	for ; err == nil; version, err = m.source.Next(version) {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if hasVersion && version <= current {
+			continue
+		}
+
+		planned, err := m.planMigration(version)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		plan = append(plan, planned)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+func (m *Migrator) planMigration(version uint) (PlannedMigration, error) {
+	r, identifier, err := m.source.ReadUp(version)
+	if err != nil {
+		return PlannedMigration{}, err
+	}
+	defer r.Close()
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return PlannedMigration{}, fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+
+	planned := PlannedMigration{
+		Version: version,
+		Title:   identifier,
+		Kind:    MigrationKindSQL,
+		SQL:     string(body),
+	}
+
+	if _, ok := m.source.migrations[version]; ok {
+		planned.Kind = MigrationKindGo
+		planned.SQL = ""
+	}
+
+	if m.cfg.Format == MigrationFormatFlyway {
+		flywayVersion, err := goMigrateVersionToFlyway(strconv.FormatUint(uint64(version), 10))
+		if err != nil {
+			return PlannedMigration{}, err
+		}
+		planned.Version = uint(flywayVersion)
+	}
+
+	return planned, nil
+}
+
+// WritePlan renders the plan returned by Migrator.Plan to w in the given format.
+func WritePlan(w io.Writer, plan []PlannedMigration, format PlanOutputFormat) error {
+	switch format {
+	case PlanOutputFormatJSON:
+		if plan == nil {
+			plan = []PlannedMigration{}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(plan)
+	case PlanOutputFormatText:
+		if len(plan) == 0 {
+			_, err := fmt.Fprintln(w, "no pending migrations")
+
+			return err
+		}
+
+		for _, p := range plan {
+			_, err := fmt.Fprintf(w, "-- migration %d %s (%s)\n", p.Version, p.Title, p.Kind)
+			if err != nil {
+				return err
+			}
+
+			if p.SQL != "" {
+				_, err = fmt.Fprintf(w, "%s\n", p.SQL)
+				if err != nil {
+					return err
+				}
+			}
+
+			_, err = fmt.Fprintln(w)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown plan output format %s", format)
+	}
+}
@@ -0,0 +1,40 @@
+package pkgsql_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgsql "github.com/amanbolat/pkg/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePlan(t *testing.T) {
+	t.Parallel()
+
+	plan := []pkgsql.PlannedMigration{
+		{Version: 1683211973, Title: "users", Kind: pkgsql.MigrationKindSQL, SQL: "CREATE TABLE users (id bigint PRIMARY KEY);"},
+		{Version: 1683211974, Title: "backfill_users", Kind: pkgsql.MigrationKindGo},
+	}
+
+	for _, format := range []pkgsql.PlanOutputFormat{pkgsql.PlanOutputFormatText, pkgsql.PlanOutputFormatJSON} {
+		var buf bytes.Buffer
+		require.NoError(t, pkgsql.WritePlan(&buf, plan, format))
+
+		golden, err := os.ReadFile(filepath.Join("testdata", "plan."+string(format)))
+		require.NoError(t, err)
+		assert.Equal(t, string(golden), buf.String(), format)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, pkgsql.WritePlan(&buf, nil, pkgsql.PlanOutputFormatJSON))
+	assert.Equal(t, "[]\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, pkgsql.WritePlan(&buf, nil, pkgsql.PlanOutputFormatText))
+	assert.Equal(t, "no pending migrations\n", buf.String())
+
+	assert.Error(t, pkgsql.WritePlan(&buf, plan, "yaml"))
+}
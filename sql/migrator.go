@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strconv"
@@ -14,7 +13,8 @@ import (
 
 	"github.com/golang-migrate/migrate/v4"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres" // import pgx driver
-	_ "github.com/golang-migrate/migrate/v4/source/file"       // import pgx driver
+	"github.com/golang-migrate/migrate/v4/source"
+	_ "github.com/golang-migrate/migrate/v4/source/file" // import pgx driver
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/spf13/afero"
 )
@@ -28,6 +28,13 @@ const goMigrateTimeFormat = "20060102150405"
 // * V1683211973__cdr_tables.sql
 var flywayFileFormatRegex = regexp.MustCompile(`^([UV])(\d+)__(\w+)\.sql$`)
 
+var (
+	// ErrNoChange is returned when there are no migrations to apply.
+	ErrNoChange = migrate.ErrNoChange
+	// ErrNoVersion is returned when no migration has been applied yet.
+	ErrNoVersion = migrate.ErrNilVersion
+)
+
 type MigratorConfig struct {
 	// MigrationsDir is a path to the migrations directory. If MigrationsFs is nil, the Migrator will use it directly.
 	// If MigrationsFs is not nil, the path in MigrationsDir is used to strip the prefix.
@@ -48,11 +55,54 @@ type MigratorConfig struct {
 // Migrator is Postgres database schem migrator.
 type Migrator struct {
 	migrator *migrate.Migrate
+	source   *goMigrationSource
+	cfg      MigratorConfig
 }
 
 // NewMigrator returns a new Migrator.
 func NewMigrator(cfg MigratorConfig) (*Migrator, error) {
-	var goMigrator *migrate.Migrate
+	src, err := newMigrationSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	goMigrations, err := registeredGoMigrations(cfg.Format)
+	if err != nil {
+		_ = src.Close()
+
+		return nil, err
+	}
+
+	goMigrationSrc, err := newGoMigrationSource(src, goMigrations)
+	if err != nil {
+		_ = src.Close()
+
+		return nil, err
+	}
+
+	dbDriver, err := newMigrationDriver(cfg.DSN, goMigrations)
+	if err != nil {
+		_ = src.Close()
+
+		return nil, err
+	}
+
+	goMigrator, err := migrate.NewWithInstance("iofs", goMigrationSrc, "postgres", dbDriver)
+	if err != nil {
+		_ = src.Close()
+		_ = dbDriver.Close()
+
+		return nil, err
+	}
+
+	pm := &Migrator{migrator: goMigrator, source: goMigrationSrc, cfg: cfg}
+
+	return pm, nil
+}
+
+// newMigrationSource returns a source driver that reads migration
+// files in go-migrate format regardless of the configured format.
+func newMigrationSource(cfg MigratorConfig) (source.Driver, error) {
 	switch cfg.Format {
 	case MigrationFormatGomigrate:
 		if cfg.MigrationsFs != nil {
@@ -61,25 +111,24 @@ func NewMigrator(cfg MigratorConfig) (*Migrator, error) {
 				return nil, fmt.Errorf("failed to create fs driver: %w", err)
 			}
 
-			goMigrator, err = migrate.NewWithSourceInstance("iofs", fsDriver, cfg.DSN)
-			if err != nil {
-				return nil, err
-			}
-		} else {
-			migrations, err := filepath.Abs(cfg.MigrationsDir)
-			if err != nil {
-				return nil, err
-			}
+			return fsDriver, nil
+		}
 
-			goMigrator, err = migrate.New(fmt.Sprintf("file://%v", migrations), cfg.DSN)
-			if err != nil {
-				return nil, err
-			}
+		migrations, err := filepath.Abs(cfg.MigrationsDir)
+		if err != nil {
+			return nil, err
 		}
+
+		return source.Open(fmt.Sprintf("file://%v", migrations))
 	case MigrationFormatFlyway:
+		fsys, err := migrationsFS(cfg)
+		if err != nil {
+			return nil, err
+		}
+
 		memFs := afero.NewMemMapFs()
 
-		err := filepath.WalkDir(cfg.MigrationsDir, func(path string, d fs.DirEntry, err error) error {
+		err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return err
 			}
@@ -88,7 +137,7 @@ func NewMigrator(cfg MigratorConfig) (*Migrator, error) {
 				return nil
 			}
 
-			tmpFile, err := os.Open(path)
+			tmpFile, err := fsys.Open(path)
 			if err != nil {
 				return err
 			}
@@ -116,22 +165,10 @@ func NewMigrator(cfg MigratorConfig) (*Migrator, error) {
 			return nil, err
 		}
 
-		migrateIOFS, err := iofs.New(afero.NewIOFS(memFs), "")
-		if err != nil {
-			return nil, err
-		}
-
-		goMigrator, err = migrate.NewWithSourceInstance("iofs", migrateIOFS, cfg.DSN)
-		if err != nil {
-			return nil, err
-		}
+		return iofs.New(afero.NewIOFS(memFs), "")
 	default:
 		return nil, errors.New("unknown migration file format")
 	}
-
-	pm := &Migrator{migrator: goMigrator}
-
-	return pm, nil
 }
 
 // MigrateUp applies up migrations.
@@ -144,6 +181,57 @@ func (m *Migrator) MigrateDown() error {
 	return m.migrator.Down()
 }
 
+// Steps applies n up migrations if n is positive
+// or n down migrations if n is negative.
+func (m *Migrator) Steps(n int) error {
+	return m.migrator.Steps(n)
+}
+
+// Version returns the currently applied migration version and whether
+// the database is in a dirty state. The version is returned in the
+// Migrator's format, i.e. unix seconds for Flyway migrations.
+// If no migration has been applied yet, ErrNoVersion is returned.
+func (m *Migrator) Version() (version uint, dirty bool, err error) {
+	version, dirty, err = m.migrator.Version()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if m.cfg.Format == MigrationFormatFlyway {
+		flywayVersion, err := goMigrateVersionToFlyway(strconv.FormatUint(uint64(version), 10))
+		if err != nil {
+			return 0, false, err
+		}
+
+		return uint(flywayVersion), dirty, nil
+	}
+
+	return version, dirty, nil
+}
+
+// Force sets the migration version without running any migrations and
+// resets the dirty state. The version must be given in the Migrator's format.
+// Version -1 means that no migration has been applied.
+func (m *Migrator) Force(version int) error {
+	if m.cfg.Format == MigrationFormatFlyway && version >= 0 {
+		goMigrateVersion, err := flywayVersionToGoMigrate(strconv.Itoa(version))
+		if err != nil {
+			return err
+		}
+
+		return m.migrator.Force(int(goMigrateVersion))
+	}
+
+	return m.migrator.Force(version)
+}
+
+// Close closes the migration source and the database connection.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.migrator.Close()
+
+	return errors.Join(srcErr, dbErr)
+}
+
 // flywayFormatToGoMigrate converts SQL migration file name from
 // Flyway format to go-migrate format.
 //
@@ -0,0 +1,284 @@
+package pkgsql
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Directives that can be used in migration files.
+//
+// Example:
+//
+//	-- +migrate NoTransaction
+//	CREATE INDEX CONCURRENTLY users_email_idx ON users (email);
+//
+//	-- +migrate StatementBegin
+//	CREATE FUNCTION f() RETURNS trigger AS $$
+//	BEGIN
+//	    RETURN NEW;
+//	END;
+//	$$ LANGUAGE plpgsql;
+//	-- +migrate StatementEnd
+const (
+	migrationDirectivePrefix = "-- +migrate "
+
+	// DirectiveNoTransaction runs every statement of the migration separately
+	// outside of a transaction. It is required for statements like
+	// CREATE INDEX CONCURRENTLY or ALTER TYPE ... ADD VALUE.
+	DirectiveNoTransaction = "NoTransaction"
+	// DirectiveStatementBegin starts a statement that is not split by semicolons.
+	DirectiveStatementBegin = "StatementBegin"
+	// DirectiveStatementEnd ends a statement started by DirectiveStatementBegin.
+	DirectiveStatementEnd = "StatementEnd"
+)
+
+var (
+	whitespaceRegex = regexp.MustCompile(`\s+`)
+	dollarTagRegex  = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+)
+
+// migrationScript is a migration file parsed with its directives.
+type migrationScript struct {
+	// HasDirectives reports whether the file contains any directives.
+	HasDirectives bool
+	NoTransaction bool
+	Statements    []string
+}
+
+// parseMigrationScript parses the directives of the migration file and
+// splits it into statements.
+func parseMigrationScript(script string) (migrationScript, error) {
+	var (
+		res     migrationScript
+		buf     strings.Builder
+		block   strings.Builder
+		inBlock bool
+	)
+
+	for _, line := range strings.SplitAfter(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, migrationDirectivePrefix) {
+			if inBlock {
+				block.WriteString(line)
+			} else {
+				buf.WriteString(line)
+			}
+
+			continue
+		}
+
+		res.HasDirectives = true
+
+		switch directive := strings.TrimSpace(strings.TrimPrefix(trimmed, migrationDirectivePrefix)); directive {
+		case DirectiveNoTransaction:
+			res.NoTransaction = true
+		case DirectiveStatementBegin:
+			if inBlock {
+				return migrationScript{}, fmt.Errorf("nested %s directive", DirectiveStatementBegin)
+			}
+			inBlock = true
+			res.Statements = append(res.Statements, splitSQL(buf.String())...)
+			buf.Reset()
+		case DirectiveStatementEnd:
+			if !inBlock {
+				return migrationScript{}, fmt.Errorf("%s directive without %s", DirectiveStatementEnd, DirectiveStatementBegin)
+			}
+			inBlock = false
+			if stmt := strings.TrimSpace(block.String()); stmt != "" {
+				res.Statements = append(res.Statements, stmt)
+			}
+			block.Reset()
+		default:
+			return migrationScript{}, fmt.Errorf("unknown migration directive: %s", directive)
+		}
+	}
+
+	if inBlock {
+		return migrationScript{}, fmt.Errorf("%s directive without %s", DirectiveStatementBegin, DirectiveStatementEnd)
+	}
+
+	res.Statements = append(res.Statements, splitSQL(buf.String())...)
+
+	return res, nil
+}
+
+// splitSQL splits the script by semicolons that are outside of string
+// literals, quoted identifiers, dollar-quoted bodies and comments.
+// Statements that contain only comments are omitted.
+func splitSQL(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		hasCode    bool
+	)
+
+	flush := func() {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+		hasCode = false
+	}
+
+	scanSQL(script, func(kind sqlSegmentKind, text string) {
+		switch kind {
+		case sqlSegmentSemicolon:
+			flush()
+		case sqlSegmentCode:
+			if strings.TrimSpace(text) != "" {
+				hasCode = true
+			}
+			current.WriteString(text)
+		case sqlSegmentQuoted:
+			hasCode = true
+			current.WriteString(text)
+		case sqlSegmentComment:
+			current.WriteString(text)
+		}
+	})
+	flush()
+
+	return statements
+}
+
+// normalizeSQL removes comments from the statement and collapses whitespace.
+func normalizeSQL(stmt string) string {
+	var sb strings.Builder
+
+	scanSQL(stmt, func(kind sqlSegmentKind, text string) {
+		if kind == sqlSegmentComment {
+			sb.WriteString(" ")
+
+			return
+		}
+		sb.WriteString(text)
+	})
+
+	return strings.TrimSpace(whitespaceRegex.ReplaceAllString(sb.String(), " "))
+}
+
+type sqlSegmentKind int
+
+const (
+	sqlSegmentCode sqlSegmentKind = iota
+	sqlSegmentQuoted
+	sqlSegmentComment
+	sqlSegmentSemicolon
+)
+
+// scanSQL splits the script into segments of code, quoted text, comments
+// and semicolons, and calls fn for each of them in order. Unterminated
+// quotes and comments extend to the end of the script.
+func scanSQL(script string, fn func(kind sqlSegmentKind, text string)) {
+	codeStart := 0
+
+	emit := func(kind sqlSegmentKind, start, end int) {
+		if codeStart < start {
+			fn(sqlSegmentCode, script[codeStart:start])
+		}
+		fn(kind, script[start:end])
+		codeStart = end
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script)
+			} else {
+				end += i
+			}
+			emit(sqlSegmentComment, i, end)
+			i = end
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := scanBlockComment(script, i)
+			emit(sqlSegmentComment, i, end)
+			i = end
+		case c == '\'':
+			escapes := i > 0 && (script[i-1] == 'E' || script[i-1] == 'e') && (i < 2 || !isIdentifierChar(script[i-2]))
+			end := scanQuoted(script, i, '\'', escapes)
+			emit(sqlSegmentQuoted, i, end)
+			i = end
+		case c == '"':
+			end := scanQuoted(script, i, '"', false)
+			emit(sqlSegmentQuoted, i, end)
+			i = end
+		case c == '$' && (i == 0 || !isIdentifierChar(script[i-1])):
+			tag := dollarTagRegex.FindString(script[i:])
+			if tag == "" {
+				i++
+
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				end = len(script)
+			} else {
+				end += i + 2*len(tag)
+			}
+			emit(sqlSegmentQuoted, i, end)
+			i = end
+		case c == ';':
+			emit(sqlSegmentSemicolon, i, i+1)
+			i++
+		default:
+			i++
+		}
+	}
+
+	if codeStart < len(script) {
+		fn(sqlSegmentCode, script[codeStart:])
+	}
+}
+
+// scanBlockComment returns the end of the block comment that starts at start.
+// Block comments can be nested in Postgres.
+func scanBlockComment(script string, start int) int {
+	depth := 0
+	for i := start; i < len(script)-1; i++ {
+		switch {
+		case script[i] == '/' && script[i+1] == '*':
+			depth++
+			i++
+		case script[i] == '*' && script[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+
+	return len(script)
+}
+
+// scanQuoted returns the end of the quoted text that starts at start.
+// Doubled quotes are treated as escaped quotes.
+func scanQuoted(script string, start int, quote byte, backslashEscapes bool) int {
+	for i := start + 1; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if backslashEscapes {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+
+				continue
+			}
+
+			return i + 1
+		}
+	}
+
+	return len(script)
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
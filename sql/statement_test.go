@@ -0,0 +1,137 @@
+package pkgsql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitSQL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "semicolons",
+			script: "SELECT 1;\nSELECT 2 ;; SELECT 3",
+			want:   []string{"SELECT 1", "SELECT 2", "SELECT 3"},
+		},
+		{
+			name:   "string literals",
+			script: `SELECT 'a;b', 'it''s;'; SELECT E'\';', e'\\'; SELECT 1`,
+			want:   []string{`SELECT 'a;b', 'it''s;'`, `SELECT E'\';', e'\\'`, "SELECT 1"},
+		},
+		{
+			name:   "backslash is not an escape in standard strings",
+			script: `SELECT '\'; SELECT 1`,
+			want:   []string{`SELECT '\'`, "SELECT 1"},
+		},
+		{
+			name:   "quoted identifiers",
+			script: `SELECT 1 AS "a;b", 2 AS "x"";"; SELECT 2`,
+			want:   []string{`SELECT 1 AS "a;b", 2 AS "x"";"`, "SELECT 2"},
+		},
+		{
+			name:   "dollar quotes",
+			script: "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql; SELECT $tag$ $$; $tag$; SELECT a$b; SELECT 1",
+			want: []string{
+				"CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql",
+				"SELECT $tag$ $$; $tag$",
+				"SELECT a$b",
+				"SELECT 1",
+			},
+		},
+		{
+			name:   "positional parameters",
+			script: "SELECT $1; SELECT $2",
+			want:   []string{"SELECT $1", "SELECT $2"},
+		},
+		{
+			name:   "comments",
+			script: "-- first; comment\nSELECT 1; /* outer /* nested; */ still; */ SELECT 2;\n-- only a comment;\n/* and; another */",
+			want:   []string{"-- first; comment\nSELECT 1", "/* outer /* nested; */ still; */ SELECT 2"},
+		},
+		{
+			name:   "unterminated quote",
+			script: "SELECT 'a; SELECT 1",
+			want:   []string{"SELECT 'a; SELECT 1"},
+		},
+		{
+			name:   "empty",
+			script: " ;\n; ",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, splitSQL(tt.script), tt.name)
+	}
+}
+
+func TestScanSQL(t *testing.T) {
+	t.Parallel()
+
+	type segment struct {
+		kind sqlSegmentKind
+		text string
+	}
+
+	var segments []segment
+	scanSQL("SELECT 'a', \"b\" /* c */ FROM t; -- d", func(kind sqlSegmentKind, text string) {
+		segments = append(segments, segment{kind: kind, text: text})
+	})
+
+	assert.Equal(t, []segment{
+		{kind: sqlSegmentCode, text: "SELECT "},
+		{kind: sqlSegmentQuoted, text: "'a'"},
+		{kind: sqlSegmentCode, text: ", "},
+		{kind: sqlSegmentQuoted, text: `"b"`},
+		{kind: sqlSegmentCode, text: " "},
+		{kind: sqlSegmentComment, text: "/* c */"},
+		{kind: sqlSegmentCode, text: " FROM t"},
+		{kind: sqlSegmentSemicolon, text: ";"},
+		{kind: sqlSegmentCode, text: " "},
+		{kind: sqlSegmentComment, text: "-- d"},
+	}, segments)
+}
+
+func TestNormalizeSQL(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "CREATE INDEX i ON t (a) WHERE b = '-- x'", normalizeSQL("CREATE  INDEX /* c */ i\n\tON t (a) -- comment\nWHERE b = '-- x'"))
+}
+
+func TestParseMigrationScript(t *testing.T) {
+	t.Parallel()
+
+	script, err := parseMigrationScript("SELECT 1;\n-- +migrate StatementBegin\nCREATE FUNCTION f() AS 'a; b';\nSELECT 2;\n-- +migrate StatementEnd\n  -- +migrate NoTransaction\nSELECT 3;")
+	require.NoError(t, err)
+	assert.Equal(t, migrationScript{
+		HasDirectives: true,
+		NoTransaction: true,
+		Statements:    []string{"SELECT 1", "CREATE FUNCTION f() AS 'a; b';\nSELECT 2;", "SELECT 3"},
+	}, script)
+
+	script, err = parseMigrationScript("SELECT 1; SELECT 2;")
+	require.NoError(t, err)
+	assert.Equal(t, migrationScript{Statements: []string{"SELECT 1", "SELECT 2"}}, script)
+
+	errTests := []struct {
+		script string
+		err    string
+	}{
+		{script: "-- +migrate StatementBegin\n-- +migrate StatementBegin\n", err: "nested StatementBegin directive"},
+		{script: "-- +migrate StatementEnd\n", err: "StatementEnd directive without StatementBegin"},
+		{script: "-- +migrate StatementBegin\nSELECT 1;", err: "StatementBegin directive without StatementEnd"},
+		{script: "-- +migrate Unknown\n", err: "unknown migration directive: Unknown"},
+	}
+
+	for _, tt := range errTests {
+		_, err := parseMigrationScript(tt.script)
+		assert.EqualError(t, err, tt.err, tt.script)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Regex to match the error code line of errcodes.txt.
+//
+// Example:
+// * 23505    E    ERRCODE_UNIQUE_VIOLATION    unique_violation
+var errCodeLineRegex = regexp.MustCompile(`^([0-9A-Z]{5})\s+([EWS])\s+ERRCODE_(\w+)(?:\s+(\w+))?`)
+
+type errorCode struct {
+	code     string
+	severity string
+	name     string
+}
+
+func main() {
+	input := flag.String("input", "", "path to errcodes.txt from the PostgreSQL source tree")
+	output := flag.String("output", "", "output go file")
+	packageName := flag.String("package", "pkgpostgres", "package name")
+	flag.Parse()
+
+	if *input == "" {
+		log.Fatalln("input was not provided")
+	}
+
+	if *output == "" {
+		log.Fatalln("output was not provided")
+	}
+
+	f, err := os.Open(*input)
+	if err != nil {
+		log.Fatalf("failed to open input file: %v", err)
+	}
+	defer f.Close()
+
+	codes, err := parse(f)
+	if err != nil {
+		log.Fatalf("failed to parse input file: %v", err)
+	}
+
+	src, err := generate(*packageName, codes)
+	if err != nil {
+		log.Fatalf("failed to generate code: %v", err)
+	}
+
+	err = os.WriteFile(*output, src, 0o644)
+	if err != nil {
+		log.Fatalf("failed to write output file: %v", err)
+	}
+}
+
+func parse(r io.Reader) ([]errorCode, error) {
+	var codes []errorCode
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		match := errCodeLineRegex.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+
+		name := match[4]
+		if name == "" {
+			name = strings.ToLower(match[3])
+		}
+
+		codes = append(codes, errorCode{
+			code:     match[1],
+			severity: match[2],
+			name:     name,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("no error codes found")
+	}
+
+	return codes, nil
+}
+
+func generate(packageName string, codes []errorCode) ([]byte, error) {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "// Code generated by generrcodes from errcodes.txt. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+
+	buf.WriteString("const (\n")
+	for _, c := range codes {
+		fmt.Fprintf(&buf, "Err%s ErrorCode = %q // %s\n", c.code, c.code, camelCase(c.name))
+	}
+	buf.WriteString(")\n\n")
+
+	buf.WriteString("var errorCodeNames = map[ErrorCode]string{\n")
+	for _, c := range codes {
+		fmt.Fprintf(&buf, "Err%s: %q,\n", c.code, c.name)
+	}
+	buf.WriteString("}\n\n")
+
+	// Some condition names are used by several codes, e.g. string_data_right_truncation
+	// is both a warning and an error. Errors take precedence over warnings.
+	byName := make(map[string]errorCode, len(codes))
+	var names []string
+	for _, c := range codes {
+		prev, ok := byName[c.name]
+		if !ok {
+			names = append(names, c.name)
+		}
+		if !ok || (prev.severity != "E" && c.severity == "E") {
+			byName[c.name] = c
+		}
+	}
+
+	buf.WriteString("var errorCodesByName = map[string]ErrorCode{\n")
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%q: Err%s,\n", name, byName[name].code)
+	}
+	buf.WriteString("}\n")
+
+	return format.Source(buf.Bytes())
+}
+
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+
+	return strings.Join(parts, "")
+}